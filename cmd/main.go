@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"github.com/Fyefhqdishka/eff-mobile/internal/app"
 	"github.com/Fyefhqdishka/eff-mobile/internal/config"
 	"github.com/joho/godotenv"
@@ -42,7 +41,7 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	if err := app.Stop(); err != nil {
-		fmt.Errorf("error during shutdown: %v", err)
+		log.Printf("error during shutdown: %v", err)
 	}
 }
 
@@ -24,19 +24,20 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/songs": {
+        "/groups": {
             "get": {
-                "description": "Returns a list of all songs with optional filtering and pagination",
+                "description": "Returns groups ordered by name with pagination",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "groups"
                 ],
-                "summary": "Get all Song's from the storage",
+                "summary": "Get all groups",
                 "parameters": [
                     {
                         "type": "integer",
+                        "default": 10,
                         "description": "Limit",
                         "name": "limit",
                         "in": "query"
@@ -46,39 +47,15 @@ const docTemplate = `{
                         "description": "Offset",
                         "name": "offset",
                         "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Song Id",
-                        "name": "id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Song title",
-                        "name": "song",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Group name",
-                        "name": "group_name",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Song release date in format 02.01.2006",
-                        "name": "releasedate",
-                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Array of Song's",
+                        "description": "Array of groups",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Song"
+                                "$ref": "#/definitions/models.Group"
                             }
                         }
                     },
@@ -89,7 +66,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Failed to get Song's",
+                        "description": "Failed to get groups",
                         "schema": {
                             "$ref": "#/definitions/handlers.Response"
                         }
@@ -97,7 +74,6 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Creates a new song with the given details",
                 "consumes": [
                     "application/json"
                 ],
@@ -105,35 +81,47 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "groups"
                 ],
-                "summary": "Create a new song",
+                "summary": "Create a new group",
                 "parameters": [
                     {
-                        "description": "Song details",
-                        "name": "song",
+                        "description": "Group name",
+                        "name": "group",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "$ref": "#/definitions/models.Group"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Created song",
+                    "201": {
+                        "description": "Created group",
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "$ref": "#/definitions/models.Group"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Group already exists",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
                         "schema": {
                             "$ref": "#/definitions/handlers.Response"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Failed to create group",
                         "schema": {
                             "$ref": "#/definitions/handlers.Response"
                         }
@@ -141,84 +129,53 @@ const docTemplate = `{
                 }
             }
         },
-        "/songs/verses": {
+        "/groups/{id}": {
             "get": {
-                "description": "Returns the verses of a song with optional filtering by group name and song name, and pagination",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "groups"
                 ],
-                "summary": "Get paginated song text (verses) from the storage",
+                "summary": "Get a group by id",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Song Id",
+                        "description": "Group Id",
                         "name": "id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number for pagination",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 5,
-                        "description": "Number of verses per page",
-                        "name": "pageSize",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Song title",
-                        "name": "song",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Group name",
-                        "name": "group_name",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Song release date in format 02.01.2006",
-                        "name": "releasedate",
-                        "in": "query"
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Array of song verses",
+                        "description": "Group",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Group"
                         }
                     },
                     "400": {
-                        "description": "Invalid query parameters",
+                        "description": "Invalid group id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
                         "schema": {
                             "$ref": "#/definitions/handlers.Response"
                         }
                     },
                     "500": {
-                        "description": "Failed to get song's verses",
+                        "description": "Failed to get group",
                         "schema": {
                             "$ref": "#/definitions/handlers.Response"
                         }
                     }
                 }
-            }
-        },
-        "/songs/{id}": {
+            },
             "put": {
-                "description": "Updated a song with the given details",
+                "description": "Renames the group for all of its songs. If another group already has the name, the request fails with 409 unless on_conflict is \"merge\", in which case the songs are moved into the existing group and this group is removed. To move a single song use PUT /songs/{id}/group.",
                 "consumes": [
                     "application/json"
                 ],
@@ -226,25 +183,32 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "groups"
                 ],
-                "summary": "Update a song",
+                "summary": "Rename a group",
                 "parameters": [
                     {
-                        "description": "Song details",
-                        "name": "song",
+                        "type": "integer",
+                        "description": "Group Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New group name and conflict handling",
+                        "name": "rename",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "$ref": "#/definitions/models.GroupRename"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updates song",
+                        "description": "Renamed or merged group",
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "$ref": "#/definitions/models.Group"
                         }
                     },
                     "400": {
@@ -253,8 +217,26 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.Response"
                         }
                     },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Group name already taken",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
                     "500": {
-                        "description": "Failed to update song",
+                        "description": "Failed to rename group",
                         "schema": {
                             "$ref": "#/definitions/handlers.Response"
                         }
@@ -262,33 +244,201 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Deleted a song",
-                "consumes": [
+                "description": "Deletes the group together with all of its songs",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Delete a group",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Group Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted group id",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid group id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete group",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/groups/{id}/refresh": {
+            "post": {
+                "description": "Refreshes a page of the songs of the group like POST /songs/{id}/refresh. A song the API fails for, that was deleted meanwhile or got invalid details is reported with its error instead of failing the whole page.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "groups"
                 ],
-                "summary": "Delete a song",
+                "summary": "Refresh the songs of a group",
                 "parameters": [
                     {
-                        "description": "Song details",
-                        "name": "song",
-                        "in": "body",
-                        "required": true,
+                        "type": "integer",
+                        "description": "Group Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "none",
+                            "missing",
+                            "all"
+                        ],
+                        "type": "string",
+                        "default": "none",
+                        "description": "Which differences to store",
+                        "name": "apply",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Replace the text of ChordPro songs with the plain upstream text",
+                        "name": "overwrite_chordpro",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Refreshed songs, meta describes the page",
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SongRefresh"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "first, prev, next and last pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid apply",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to refresh songs",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
                         }
                     }
+                }
+            }
+        },
+        "/groups/{id}/songs": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Get songs of a group",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Group Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "default": "legacy",
+                        "description": "Layout of returned dates",
+                        "name": "date_format",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updates song",
+                        "description": "Array of Song's, meta describes the page",
                         "schema": {
-                            "$ref": "#/definitions/models.Song"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Song"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "first, prev, next and last pages"
+                            }
                         }
                     },
                     "400": {
@@ -297,32 +447,1680 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.Response"
                         }
                     },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
                     "500": {
-                        "description": "Failed to update song",
+                        "description": "Failed to get songs",
                         "schema": {
                             "$ref": "#/definitions/handlers.Response"
                         }
                     }
                 }
             }
-        }
-    },
+        },
+        "/health/upstream": {
+            "get": {
+                "description": "Returns the state of the circuit breaker guarding the music info API. While it is open, enrichment of new songs is postponed until retry_at. With several providers configured, state is the best of their states and providers lists each of them. With the details cache turned on, cache holds its counters since the start.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Enrichment API status",
+                "responses": {
+                    "200": {
+                        "description": "Circuit breaker state and cache counters",
+                        "schema": {
+                            "$ref": "#/definitions/models.UpstreamStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs": {
+            "get": {
+                "description": "Returns a list of all songs with optional filtering and pagination. Dates are accepted as 02.01.2006 or ISO-8601 (2006-01-02). With match=fuzzy song and group_name tolerate typos, results are ordered by similarity unless sort is given and carry a score.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get all Song's from the storage",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Song title",
+                        "name": "song",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Group name",
+                        "name": "group_name",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "exact",
+                            "prefix",
+                            "contains",
+                            "fuzzy"
+                        ],
+                        "type": "string",
+                        "default": "exact",
+                        "description": "How song and group_name are matched, case-insensitively",
+                        "name": "match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page, continues the listing in its sort order instead of offset. The filters must stay the same",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "-releasedate,group_name,song",
+                        "description": "Comma separated sort keys out of id, song, group_name and releasedate, - prefix for descending. Ties are broken by id, songs without a release date come last",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exact release date",
+                        "name": "releasedate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Released on or after this date",
+                        "name": "released_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Released on or before this date",
+                        "name": "released_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Release year",
+                        "name": "year",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "default": "legacy",
+                        "description": "Layout of returned dates",
+                        "name": "date_format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Array of Song's, meta describes the page and next_cursor points to the next one",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Song"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "first, prev, next and last pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters or cursor",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Contradicting filters, fuzzy match without a name, cursor combined with offset or issued for another filter or sort order",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get Song's",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Stores a new song with the given details right away. The missing text, link and release date are filled in from the music info API in the background, see GET /songs/{id}/enrichment.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Create a new song",
+                "parameters": [
+                    {
+                        "description": "Song details",
+                        "name": "song",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created song",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/search": {
+            "get": {
+                "description": "Searches song titles, group names and lyrics with postgres full-text search. Results are ordered by rank and carry a lyrics snippet with matches wrapped in \u003cb\u003e\u003c/b\u003e. Supports web search syntax: quoted phrases, OR and -exclusion.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Full-text search over songs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search query",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "en",
+                            "ru"
+                        ],
+                        "type": "string",
+                        "description": "Text search language, both when omitted",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "default": "legacy",
+                        "description": "Layout of returned dates",
+                        "name": "date_format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ranked search results",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SongSearchResult"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Missing query or unsupported language",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to search songs",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/verses": {
+            "get": {
+                "description": "Returns the verses of a song as strings, lines of a verse are joined with \\n. Prefer GET /songs/{id}/verses.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get paginated song text (verses) from the storage",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 5,
+                        "description": "Number of verses per page",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated section types to keep",
+                        "name": "type",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Array of song verses, meta describes the page",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "first, prev, next and last pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found or page out of range",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get song's verses",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}": {
+            "get": {
+                "description": "Returns the song with the given id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get a song by id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "default": "legacy",
+                        "description": "Layout of returned dates",
+                        "name": "date_format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Song",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get song",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Updated a song with the given details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Update a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Song details",
+                        "name": "song",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updates song",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Body id differs from path id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update song",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deleted a song",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Delete a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted song id",
+                        "schema": {
+                            "type": "integer"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete song",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Updates only the supplied fields. Accepts RFC 7396 merge patch (application/merge-patch+json or application/json) and RFC 6902 JSON patch (application/json-patch+json) with add, replace and remove operations. A null or removed text, link or releasedate clears the field, a cleared text_format falls back to plain.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Partially update a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch or JSON patch document",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated song",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Body id differs from path id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported patch format",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid patch",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to patch song",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/chords": {
+            "get": {
+                "description": "Parses the ChordPro text of the song, transposes it and renders it as JSON with chords positioned at character offsets of the lyric lines, as plain text with chords above the lyrics or as an HTML page. Sharps or flats follow the resulting {key}, sheets without a key keep the accidentals of each chord.",
+                "produces": [
+                    "application/json",
+                    "text/plain",
+                    "text/html"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get the chord sheet of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Semitones to transpose by, -11 to 11, e.g. 2 or -3 (a literal + must be sent as %2B)",
+                        "name": "transpose",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "latin",
+                            "german",
+                            "solfege"
+                        ],
+                        "type": "string",
+                        "default": "latin",
+                        "description": "Chord notation",
+                        "name": "notation",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "text",
+                            "html"
+                        ],
+                        "type": "string",
+                        "default": "json",
+                        "description": "Response format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Chord sheet",
+                        "schema": {
+                            "$ref": "#/definitions/models.ChordSheet"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found or not written in ChordPro",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Transpose or notation out of range",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get chords",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/enrichment": {
+            "get": {
+                "description": "New songs are enriched from the music info API in the background. A pending song waits for a worker until next_attempt_at, a failed one ran out of attempts or got an answer retrying won't change, a skipped one was stored while enrichment was turned off or with all the fields already set.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get the enrichment status of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Enrichment status",
+                        "schema": {
+                            "$ref": "#/definitions/models.Enrichment"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get enrichment status",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/group": {
+            "put": {
+                "description": "Reassigns only this song to the group with the given name, creating the group if needed. Other songs of the previous group are not affected. To rename a group for all of its songs use PUT /groups/{id}.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Move a song to another group",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Target group name",
+                        "name": "group",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Group"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated song",
+                        "schema": {
+                            "$ref": "#/definitions/models.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to move song",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/lyrics": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Get timed lyrics of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Timed lyrics",
+                        "schema": {
+                            "$ref": "#/definitions/models.TimedLyrics"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song or its timed lyrics not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get lyrics",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Imports an .lrc file sent as the request body or as the file field of a multipart form, replacing the stored timed lyrics. ID tags ([ar:], [ti:], ...) are kept as metadata, [offset:] shifts every line, lines with several timestamps are repeated at each of them.",
+                "consumes": [
+                    "text/plain",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Upload timed lyrics of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "LRC file when sent as a multipart form",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Imported lyrics",
+                        "schema": {
+                            "$ref": "#/definitions/models.TimedLyrics"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid id or form",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "413": {
+                        "description": "File larger than 1 MiB",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Malformed LRC",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store lyrics",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/lyrics.lrc": {
+            "get": {
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Download timed lyrics as LRC",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "LRC file",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song or its timed lyrics not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get lyrics",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/lyrics/at": {
+            "get": {
+                "description": "Returns the line shown at the playback position t with its index and the line that follows. Before the first line index is -1 and line is null.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Get the active line of timed lyrics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Playback position in seconds (12.5) or as a duration (1m12.5s)",
+                        "name": "t",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Active line",
+                        "schema": {
+                            "$ref": "#/definitions/models.LyricsPosition"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid id or position",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song or its timed lyrics not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Negative, infinite or NaN position",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get lyrics",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/refresh": {
+            "post": {
+                "description": "Fetches the text, link and release date of the song again, bypassing the details cache, and returns the fields whose upstream value differs from the stored one. apply=none only previews the differences, missing fills in the fields the song has no value for, all overwrites every differing field. Fields the API has no value for are left alone. The API has no chords, so the text of a ChordPro song is only replaced with overwrite_chordpro=true and then becomes plain. An applied refresh marks the enrichment of the song as done.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Refresh song details from the music info API",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "none",
+                            "missing",
+                            "all"
+                        ],
+                        "type": "string",
+                        "default": "none",
+                        "description": "Which differences to store",
+                        "name": "apply",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Replace the text of a ChordPro song with the plain upstream text",
+                        "name": "overwrite_chordpro",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Differences, applied ones are marked",
+                        "schema": {
+                            "$ref": "#/definitions/models.SongRefresh"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid apply",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to refresh song",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "Music info API failed",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/sections": {
+            "get": {
+                "description": "Returns the lyrics split into sections by headers like [Verse 2], [Chorus] or [Bridge], stanzas without a header are verses. A header without lines repeats an earlier section and is marked with repeat, expand fills in its lines.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get the structured lyrics of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fill in the lines of repeated sections",
+                        "name": "expand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated section types to keep",
+                        "name": "type",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Sections in song order",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Section"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get sections",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/verses": {
+            "get": {
+                "description": "Returns the verses of a song, verses are stanzas of the lyrics separated by blank lines or section headers like [Chorus]. Each verse carries its number, section type and lines, repeated sections are expanded.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get paginated verses of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 5,
+                        "description": "Number of verses per page",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated section types to keep, e.g. chorus. Types are verse, pre-chorus, chorus, bridge, intro, outro, hook and other",
+                        "name": "type",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Verses of the page, meta.total is the number of verses",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Verse"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "first, prev, next and last pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid id or pagination parameters",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found or page out of range",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get song's verses",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        }
+    },
     "definitions": {
+        "handlers.Meta": {
+            "type": "object",
+            "properties": {
+                "has_next": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next_cursor": {
+                    "description": "NextCursor continues the listing after this page, empty on the last one",
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "page": {
+                    "description": "Page is the 1-based page number, unknown for cursor pages",
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "Total counts the items of all pages",
+                    "type": "integer"
+                }
+            }
+        },
         "handlers.Response": {
             "type": "object",
             "properties": {
+                "code": {
+                    "type": "string"
+                },
                 "message": {
                     "type": "string"
                 },
+                "meta": {
+                    "$ref": "#/definitions/handlers.Meta"
+                },
                 "result": {},
                 "status": {
                     "type": "string"
                 }
             }
         },
+        "models.CacheStats": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "type": "integer"
+                },
+                "entries": {
+                    "description": "Entries is the number of cached lookups, Capacity their limit",
+                    "type": "integer"
+                },
+                "evictions": {
+                    "description": "Evictions counts the entries dropped to make room, expired ones don't\ncount",
+                    "type": "integer"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "negative_hits": {
+                    "description": "NegativeHits are the hits answered with a cached not found",
+                    "type": "integer"
+                },
+                "shared": {
+                    "description": "Shared counts the misses that joined a lookup already in flight",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ChordLine": {
+            "type": "object",
+            "properties": {
+                "chords": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChordPosition"
+                    }
+                },
+                "comment": {
+                    "description": "Comment marks a {comment} directive, Text is the comment",
+                    "type": "boolean"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ChordPosition": {
+            "type": "object",
+            "properties": {
+                "chord": {
+                    "type": "string"
+                },
+                "offset": {
+                    "description": "Offset counts characters, not bytes, from the start of the line text.\nIt equals the text length for a chord after the last word.",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ChordSection": {
+            "type": "object",
+            "properties": {
+                "label": {
+                    "type": "string"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChordLine"
+                    }
+                },
+                "repeat": {
+                    "description": "Repeat marks a {chorus} directive that repeats the latest chorus",
+                    "type": "boolean"
+                },
+                "type": {
+                    "description": "Type is one of the Section* constants",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ChordSheet": {
+            "type": "object",
+            "properties": {
+                "artist": {
+                    "type": "string"
+                },
+                "capo": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "meta": {
+                    "description": "Meta holds the other directives with a value, e.g. {tempo: 120}",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "sections": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChordSection"
+                    }
+                },
+                "subtitle": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Enrichment": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Attempts counts the finished attempts so far",
+                    "type": "integer"
+                },
+                "last_error": {
+                    "description": "LastError is the error of the latest failed attempt",
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "description": "NextAttemptAt is when a pending song is tried next",
+                    "type": "string"
+                },
+                "sources": {
+                    "description": "Sources names the provider each enriched field came from",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.FieldSources"
+                        }
+                    ]
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "done",
+                        "failed",
+                        "skipped"
+                    ]
+                }
+            }
+        },
+        "models.FieldDiff": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "Applied marks the fields overwritten with the upstream value",
+                    "type": "boolean"
+                },
+                "field": {
+                    "type": "string",
+                    "enum": [
+                        "text",
+                        "link",
+                        "releasedate"
+                    ]
+                },
+                "source": {
+                    "description": "Source is the provider the upstream value came from, when several are\nconfigured",
+                    "type": "string"
+                },
+                "stored": {
+                    "type": "string"
+                },
+                "upstream": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FieldSources": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
+        "models.Group": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.GroupRename": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "on_conflict": {
+                    "type": "string",
+                    "default": "error",
+                    "enum": [
+                        "error",
+                        "merge"
+                    ]
+                }
+            }
+        },
+        "models.LyricsPosition": {
+            "type": "object",
+            "properties": {
+                "index": {
+                    "description": "Index of the active line, -1 before the first line starts",
+                    "type": "integer"
+                },
+                "line": {
+                    "description": "Line is the active line, nil before the first line starts",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.TimedLine"
+                        }
+                    ]
+                },
+                "next": {
+                    "description": "Next is the line that follows, nil after the last one",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.TimedLine"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.ProviderStatus": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "retry_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string",
+                    "enum": [
+                        "closed",
+                        "open",
+                        "half-open"
+                    ]
+                }
+            }
+        },
+        "models.Section": {
+            "type": "object",
+            "properties": {
+                "label": {
+                    "description": "Label is the header as written in the lyrics, empty for unmarked stanzas",
+                    "type": "string"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "number": {
+                    "description": "Number tells apart sections of the same type, e.g. 2 for [Verse 2]",
+                    "type": "integer"
+                },
+                "repeat": {
+                    "description": "Repeat marks a header without lines that repeats an earlier section",
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
         "models.Song": {
             "type": "object",
             "properties": {
+                "group_id": {
+                    "type": "integer"
+                },
+                "group_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "releasedate": {
+                    "type": "string",
+                    "example": "02.01.2006"
+                },
+                "score": {
+                    "description": "Score is the similarity to the filter, only set for fuzzy matches",
+                    "type": "number"
+                },
+                "song": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "text_format": {
+                    "description": "TextFormat tells how Text is written, one of the TextFormat* constants",
+                    "type": "string",
+                    "default": "plain",
+                    "enum": [
+                        "plain",
+                        "chordpro"
+                    ]
+                }
+            }
+        },
+        "models.SongRefresh": {
+            "type": "object",
+            "properties": {
+                "apply": {
+                    "type": "string",
+                    "enum": [
+                        "none",
+                        "missing",
+                        "all"
+                    ]
+                },
+                "diff": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldDiff"
+                    }
+                },
+                "error": {
+                    "description": "Error is why the song couldn't be refreshed, only a group refresh\nreports it per song instead of failing",
+                    "type": "string"
+                },
+                "group_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "song": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SongSearchResult": {
+            "type": "object",
+            "properties": {
+                "group_id": {
+                    "type": "integer"
+                },
                 "group_name": {
                     "type": "string"
                 },
@@ -332,7 +2130,19 @@ const docTemplate = `{
                 "link": {
                     "type": "string"
                 },
+                "rank": {
+                    "type": "number"
+                },
                 "releasedate": {
+                    "type": "string",
+                    "example": "02.01.2006"
+                },
+                "score": {
+                    "description": "Score is the similarity to the filter, only set for fuzzy matches",
+                    "type": "number"
+                },
+                "snippet": {
+                    "description": "Snippet is a fragment of the lyrics with matches wrapped in \u003cb\u003e\u003c/b\u003e",
                     "type": "string"
                 },
                 "song": {
@@ -340,6 +2150,106 @@ const docTemplate = `{
                 },
                 "text": {
                     "type": "string"
+                },
+                "text_format": {
+                    "description": "TextFormat tells how Text is written, one of the TextFormat* constants",
+                    "type": "string",
+                    "default": "plain",
+                    "enum": [
+                        "plain",
+                        "chordpro"
+                    ]
+                }
+            }
+        },
+        "models.TimedLine": {
+            "type": "object",
+            "properties": {
+                "text": {
+                    "type": "string"
+                },
+                "time_ms": {
+                    "description": "Time is the position in milliseconds as written in the file",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.TimedLyrics": {
+            "type": "object",
+            "properties": {
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TimedLine"
+                    }
+                },
+                "meta": {
+                    "description": "Meta holds the ID tags of the file such as ar, ti or al",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "offset_ms": {
+                    "description": "Offset in milliseconds, positive values show every line earlier",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.UpstreamStatus": {
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "description": "Cache is set when the details are cached in front of the client",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.CacheStats"
+                        }
+                    ]
+                },
+                "failures": {
+                    "description": "Failures counts the consecutive failed requests, of the provider with\nthe fewest when there are several",
+                    "type": "integer"
+                },
+                "providers": {
+                    "description": "Providers are the states of each provider when several are configured,\nState is then the best of them",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ProviderStatus"
+                    }
+                },
+                "retry_at": {
+                    "description": "RetryAt is when an open circuit lets the next request through",
+                    "type": "string"
+                },
+                "state": {
+                    "description": "State is closed, open or half-open, disabled when enrichment is off",
+                    "type": "string",
+                    "enum": [
+                        "closed",
+                        "open",
+                        "half-open",
+                        "disabled"
+                    ]
+                }
+            }
+        },
+        "models.Verse": {
+            "type": "object",
+            "properties": {
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "number": {
+                    "description": "Number is the 1-based position of the verse in the song",
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "Type is the section type of the verse, see the Section* constants",
+                    "type": "string"
                 }
             }
         }
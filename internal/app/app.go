@@ -23,6 +23,8 @@ import (
 type App struct {
 	db     *sql.DB
 	server *http.Server
+	// stopEnrichment stops the enrichment workers and waits for them
+	stopEnrichment func()
 }
 
 func (s *App) Run() error {
@@ -35,14 +37,17 @@ func (s *App) Run() error {
 func (s *App) Stop() error {
 	var errs []error
 
-	if err := s.db.Close(); err != nil {
-		errs = append(errs, fmt.Errorf("failed to close database: %v", err))
-	}
-
 	if err := s.server.Shutdown(context.Background()); err != nil {
 		errs = append(errs, fmt.Errorf("failed to shutdown server: %v", err))
 	}
 
+	// the workers need the database to record what they were doing
+	s.stopEnrichment()
+
+	if err := s.db.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close database: %v", err))
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("shutdown errors: %v", errs)
 	}
@@ -71,25 +76,39 @@ func New(cfg *config.Config) (*App, error) {
 
 	log := initLogging()
 
-	baseURL := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
+	client := newClient(cfg.Upstream, log)
 
-	client := client.NewClient(baseURL, log)
+	songStorage := repositories.NewSongRepository(db, log)
+	groupStorage := repositories.NewGroupRepository(db, log)
 
-	storage := repositories.NewSongRepository(db, log)
+	tx := storage.NewTxManager(db, log)
 
-	service := service.NewService(storage, client, log)
+	groupService := service.NewGroupService(groupStorage, songStorage, tx, log)
+	service := service.NewService(songStorage, groupStorage, tx, client, log)
+	service.SkipComplete = cfg.Enrichment.SkipComplete
 
-	h := *handlers.NewHandlers(log, service)
+	h := *handlers.NewHandlers(log, service, groupService, cfg.Server.CursorSecret)
 
 	r := mux.NewRouter()
+	r.Use(handlers.Timeout(cfg.Server.Timeout))
 	routes.RegisterRoutes(r, h)
 
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
 
-	log.Info("server starting in port", cfg.Server.Port)
+	log.Info("server starting", slog.String("port", cfg.Server.Port))
+	if cfg.Server.EphemeralCursorSecret {
+		log.Warn("SRV_CURSOR_SECRET is not set, pagination cursors won't survive a restart or work across replicas")
+	}
+
+	enrichCtx, cancel := context.WithCancel(context.Background())
+	wait := service.StartEnrichment(enrichCtx, enrichmentConfig(cfg.Enrichment))
 
 	app := &App{
 		db: db,
+		stopEnrichment: func() {
+			cancel()
+			wait()
+		},
 		server: &http.Server{
 			Addr:         addr,
 			Handler:      r,
@@ -102,6 +121,69 @@ func New(cfg *config.Config) (*App, error) {
 	return app, nil
 }
 
+// newClient builds the enrichment API client, a chain of them when several
+// providers are configured, or a stand-in when enrichment is turned off
+func newClient(cfg config.Upstream, log *slog.Logger) client.ClientInterface {
+	if !cfg.Enabled {
+		log.Info("enrichment API disabled")
+		return client.Disabled{}
+	}
+
+	providers := make([]client.Provider, len(cfg.Providers))
+	for i, p := range cfg.Providers {
+		log.Info("enrichment API configured",
+			slog.String("provider", p.Name),
+			slog.String("url", p.URL.String()),
+			slog.Bool("auth", p.Token != ""))
+
+		providers[i] = client.Provider{
+			Name: p.Name,
+			Client: client.NewClient(client.Config{
+				BaseURL:          p.URL,
+				AuthHeader:       p.AuthHeader,
+				Token:            p.Token,
+				TLS:              p.TLS,
+				Timeout:          cfg.Timeout,
+				Retries:          cfg.Retries,
+				Backoff:          cfg.Backoff,
+				MaxBackoff:       cfg.MaxBackoff,
+				BreakerThreshold: cfg.BreakerFailures,
+				BreakerCooldown:  cfg.BreakerCooldown,
+			}, log.With(slog.String("provider", p.Name))),
+		}
+	}
+
+	c := providers[0].Client
+	if len(providers) > 1 {
+		log.Info("enrichment providers chained", slog.String("merge", cfg.Merge), slog.Bool("parallel", cfg.Parallel))
+		c = client.NewChain(providers, cfg.Merge, cfg.Parallel, log)
+	}
+
+	if cfg.CacheSize > 0 {
+		log.Info("enrichment details cached", slog.Int("size", cfg.CacheSize), slog.Duration("ttl", cfg.CacheTTL))
+		c = client.NewCache(c, client.CacheConfig{
+			Size:        cfg.CacheSize,
+			TTL:         cfg.CacheTTL,
+			NegativeTTL: cfg.CacheNegativeTTL,
+		}, log)
+	}
+
+	return c
+}
+
+func enrichmentConfig(cfg config.Enrichment) service.EnrichmentConfig {
+	return service.EnrichmentConfig{
+		Workers:      cfg.Workers,
+		BatchSize:    cfg.BatchSize,
+		PollInterval: cfg.PollInterval,
+		Lease:        cfg.Lease,
+		MaxAttempts:  cfg.MaxAttempts,
+		Backoff:      cfg.Backoff,
+		MaxBackoff:   cfg.MaxBackoff,
+		Merge:        cfg.Merge,
+	}
+}
+
 func initLogging() *slog.Logger {
 	logFileName := "logs/app-" + time.Now().Format("2006-01-02") + ".log"
 	logfile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
@@ -0,0 +1,171 @@
+package chordpro
+
+import (
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"regexp"
+	"strings"
+)
+
+var (
+	// a root note with an optional accidental, the quality and an optional
+	// bass note, e.g. C#m7/G#
+	chordRe = regexp.MustCompile(`^([A-G])([#b]?)([^/]*)(?:/([A-G])([#b]?))?$`)
+
+	accidentals = strings.NewReplacer("♯", "#", "♭", "b")
+)
+
+var (
+	sharpNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	flatNames  = []string{"C", "Db", "D", "Eb", "E", "F", "Gb", "G", "Ab", "A", "Bb", "B"}
+	solfege    = map[string]string{"C": "Do", "D": "Re", "E": "Mi", "F": "Fa", "G": "Sol", "A": "La", "B": "Si"}
+
+	naturals = map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+
+	// keys written with flats, by the semitone of their tonic
+	flatMajorKeys = map[int]bool{5: true, 10: true, 3: true, 8: true, 1: true, 6: true}
+	flatMinorKeys = map[int]bool{2: true, 7: true, 0: true, 5: true, 10: true, 3: true}
+)
+
+// IsNotation reports whether notation is a known chord notation
+func IsNotation(notation string) bool {
+	switch notation {
+	case models.NotationLatin, models.NotationGerman, models.NotationSolfege:
+		return true
+	}
+
+	return false
+}
+
+// chord is a parsed chord name, notes are semitones above C
+type chord struct {
+	root    int
+	quality string
+	// bass is -1 for chords without a bass note
+	bass int
+	flat bool
+}
+
+// parseChord reads a chord in latin notation, names like N.C. don't parse
+func parseChord(name string) (chord, bool) {
+	m := chordRe.FindStringSubmatch(accidentals.Replace(name))
+	if m == nil {
+		return chord{}, false
+	}
+
+	c := chord{root: note(m[1], m[2]), quality: m[3], bass: -1, flat: m[2] == "b"}
+	if m[4] != "" {
+		c.bass = note(m[4], m[5])
+		c.flat = c.flat || m[5] == "b"
+	}
+
+	return c, true
+}
+
+func note(letter, accidental string) int {
+	n := naturals[letter]
+	switch accidental {
+	case "#":
+		n++
+	case "b":
+		n--
+	}
+
+	return (n + 12) % 12
+}
+
+// minor reports whether the quality makes a minor chord, m7 is but maj7 isn't
+func (c chord) minor() bool {
+	return strings.HasPrefix(c.quality, "m") && !strings.HasPrefix(c.quality, "maj")
+}
+
+func (c chord) transpose(semitones int) chord {
+	c.root = (c.root + semitones%12 + 12) % 12
+	if c.bass >= 0 {
+		c.bass = (c.bass + semitones%12 + 12) % 12
+	}
+
+	return c
+}
+
+func (c chord) format(flat bool, notation string) string {
+	name := noteName(c.root, flat, notation) + c.quality
+	if c.bass >= 0 {
+		name += "/" + noteName(c.bass, flat, notation)
+	}
+
+	return name
+}
+
+func noteName(n int, flat bool, notation string) string {
+	name := sharpNames[n]
+	if flat {
+		name = flatNames[n]
+	}
+
+	switch notation {
+	case models.NotationGerman:
+		switch name {
+		case "B":
+			return "H"
+		case "Bb":
+			return "B"
+		}
+	case models.NotationSolfege:
+		return solfege[name[:1]] + name[1:]
+	}
+
+	return name
+}
+
+// Transpose moves every chord and the key of the sheet by semitones and
+// writes them in notation. Sharps or flats are picked by the resulting key,
+// chords of a sheet without a key keep their own accidentals. Chords that
+// don't parse, like N.C., are left as they are.
+func Transpose(sheet models.ChordSheet, semitones int, notation string) models.ChordSheet {
+	flatKey, hasKey := false, false
+	if key, ok := parseChord(sheet.Key); ok {
+		key = key.transpose(semitones)
+		flatKey, hasKey = key.flat, true
+		if semitones%12 != 0 {
+			flatKey = flatMajorKeys[key.root]
+			if key.minor() {
+				flatKey = flatMinorKeys[key.root]
+			}
+		}
+		sheet.Key = key.format(flatKey, notation)
+	}
+
+	rename := func(name string) string {
+		c, ok := parseChord(name)
+		if !ok {
+			return name
+		}
+
+		flat := c.flat
+		if hasKey && semitones%12 != 0 {
+			flat = flatKey
+		}
+
+		return c.transpose(semitones).format(flat, notation)
+	}
+
+	sections := make([]models.ChordSection, len(sheet.Sections))
+	for i, s := range sheet.Sections {
+		lines := make([]models.ChordLine, len(s.Lines))
+		for j, line := range s.Lines {
+			if len(line.Chords) > 0 {
+				chords := make([]models.ChordPosition, len(line.Chords))
+				for k, pos := range line.Chords {
+					chords[k] = models.ChordPosition{Offset: pos.Offset, Chord: rename(pos.Chord)}
+				}
+				line.Chords = chords
+			}
+			lines[j] = line
+		}
+		s.Lines = lines
+		sections[i] = s
+	}
+	sheet.Sections = sections
+
+	return sheet
+}
@@ -0,0 +1,252 @@
+// Package chordpro parses songs in the ChordPro format, lyrics with chords in
+// brackets right before the syllable they are played on, and transposes and
+// renders the resulting chord sheets.
+package chordpro
+
+import (
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var directiveRe = regexp.MustCompile(`^\{\s*([A-Za-z_]+)\s*(?::\s*(.*?))?\s*\}$`)
+
+// environment is a section opened or closed by a directive
+type environment struct {
+	typ   string
+	start bool
+}
+
+var environments = map[string]environment{
+	"start_of_chorus": {models.SectionChorus, true},
+	"soc":             {models.SectionChorus, true},
+	"end_of_chorus":   {models.SectionChorus, false},
+	"eoc":             {models.SectionChorus, false},
+	"start_of_verse":  {models.SectionVerse, true},
+	"sov":             {models.SectionVerse, true},
+	"end_of_verse":    {models.SectionVerse, false},
+	"eov":             {models.SectionVerse, false},
+	"start_of_bridge": {models.SectionBridge, true},
+	"sob":             {models.SectionBridge, true},
+	"end_of_bridge":   {models.SectionBridge, false},
+	"eob":             {models.SectionBridge, false},
+	"start_of_tab":    {models.SectionTab, true},
+	"sot":             {models.SectionTab, true},
+	"end_of_tab":      {models.SectionTab, false},
+	"eot":             {models.SectionTab, false},
+}
+
+// Parse reads a ChordPro song. Lines outside of {start_of_...} blocks form
+// verses separated by blank lines, tab blocks are kept verbatim. Directives
+// other than the known ones are kept in Meta when they have a value.
+func Parse(text string) (models.ChordSheet, error) {
+	p := parser{sheet: models.ChordSheet{Meta: map[string]string{}, Sections: []models.ChordSection{}}}
+
+	for n, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		n++
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if err := p.line(line); err != nil {
+			return models.ChordSheet{}, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	p.flush()
+
+	if len(p.sheet.Meta) == 0 {
+		p.sheet.Meta = nil
+	}
+
+	return p.sheet, nil
+}
+
+type parser struct {
+	sheet models.ChordSheet
+	cur   *models.ChordSection
+	// env is set while inside a block opened by a directive
+	env bool
+}
+
+func (p *parser) line(line string) error {
+	trimmed := strings.TrimSpace(line)
+	m := directiveRe.FindStringSubmatch(trimmed)
+
+	switch {
+	case p.env && p.cur.Type == models.SectionTab && m == nil:
+		p.cur.Lines = append(p.cur.Lines, models.ChordLine{Text: line})
+	case trimmed == "":
+		if !p.env {
+			p.flush()
+		}
+	case strings.HasPrefix(trimmed, "#"):
+	case m != nil:
+		return p.directive(strings.ToLower(m[1]), m[2])
+	case strings.HasPrefix(trimmed, "{"):
+		return fmt.Errorf("unclosed directive")
+	default:
+		chordLine, err := parseLine(line)
+		if err != nil {
+			return err
+		}
+		s := p.section()
+		s.Lines = append(s.Lines, chordLine)
+	}
+
+	return nil
+}
+
+// flush ends the current section, sections without lines are dropped
+func (p *parser) flush() {
+	if p.cur != nil && len(p.cur.Lines) > 0 {
+		p.sheet.Sections = append(p.sheet.Sections, *p.cur)
+	}
+	p.cur, p.env = nil, false
+}
+
+// section returns the current section, starting a verse if there is none
+func (p *parser) section() *models.ChordSection {
+	if p.cur == nil {
+		p.cur = &models.ChordSection{Type: models.SectionVerse, Lines: []models.ChordLine{}}
+	}
+	return p.cur
+}
+
+// directive applies a {name: value} line
+func (p *parser) directive(name, value string) error {
+	if e, ok := environments[name]; ok {
+		p.flush()
+		if e.start {
+			p.cur = &models.ChordSection{Type: e.typ, Label: value, Lines: []models.ChordLine{}}
+			p.env = true
+		}
+		return nil
+	}
+
+	switch name {
+	case "title", "t":
+		p.sheet.Title = value
+	case "subtitle", "st":
+		p.sheet.Subtitle = value
+	case "artist":
+		p.sheet.Artist = value
+	case "key":
+		p.sheet.Key = value
+	case "capo":
+		capo, err := strconv.Atoi(value)
+		if err != nil || capo < 0 {
+			return fmt.Errorf("invalid capo %q", value)
+		}
+		p.sheet.Capo = capo
+	case "comment", "c", "comment_italic", "ci", "comment_box", "cb", "highlight":
+		s := p.section()
+		s.Lines = append(s.Lines, models.ChordLine{Text: value, Comment: true})
+	case "chorus":
+		// a chorus repeat stands on its own between paragraphs
+		if !p.env {
+			p.flush()
+		}
+		p.sheet.Sections = append(p.sheet.Sections, models.ChordSection{
+			Type:   models.SectionChorus,
+			Label:  value,
+			Lines:  []models.ChordLine{},
+			Repeat: true,
+		})
+	default:
+		if value != "" {
+			p.sheet.Meta[name] = value
+		}
+	}
+
+	return nil
+}
+
+// parseLine splits the chords out of a lyrics line
+func parseLine(s string) (models.ChordLine, error) {
+	var (
+		line   models.ChordLine
+		text   strings.Builder
+		offset int
+	)
+
+	for {
+		open := strings.IndexByte(s, '[')
+		if open < 0 {
+			text.WriteString(s)
+			break
+		}
+		end := strings.IndexByte(s[open:], ']')
+		if end < 0 {
+			return models.ChordLine{}, fmt.Errorf("unclosed chord")
+		}
+		end += open
+
+		chord := strings.TrimSpace(s[open+1 : end])
+		if chord == "" {
+			return models.ChordLine{}, fmt.Errorf("empty chord")
+		}
+
+		text.WriteString(s[:open])
+		offset += utf8.RuneCountInString(s[:open])
+		line.Chords = append(line.Chords, models.ChordPosition{Offset: offset, Chord: chord})
+		s = s[end+1:]
+	}
+
+	line.Text = text.String()
+
+	return line, nil
+}
+
+// Lyrics returns the lyrics of the sheet without chords, marked up with
+// [Chorus] style headers for the lyrics package. Comments and tabs are left
+// out.
+func Lyrics(sheet models.ChordSheet) string {
+	var b strings.Builder
+
+	for _, s := range sheet.Sections {
+		if s.Type == models.SectionTab {
+			continue
+		}
+
+		var lines []string
+		for _, line := range s.Lines {
+			if !line.Comment {
+				lines = append(lines, line.Text)
+			}
+		}
+		if len(lines) == 0 && !s.Repeat {
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if s.Type != models.SectionVerse || s.Label != "" {
+			fmt.Fprintf(&b, "[%s]\n", header(s))
+		}
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// header names a section so that the lyrics package reads back its type
+func header(s models.ChordSection) string {
+	name := strings.ToUpper(s.Type[:1]) + s.Type[1:]
+	if s.Label == "" {
+		return name
+	}
+	if strings.HasPrefix(strings.ToLower(s.Label), s.Type) {
+		return s.Label
+	}
+
+	return name + ": " + s.Label
+}
@@ -0,0 +1,125 @@
+package chordpro_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/chordpro"
+	"github.com/Fyefhqdishka/eff-mobile/internal/lyrics"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const song = `{title: Let It Be}
+{artist: The Beatles}
+{key: C}
+{tempo: 72}
+# a comment line
+
+When I [C]find myself in [G]times of trouble
+[Am]Mother Mary [F]comes to me
+
+{start_of_chorus}
+Let it [Am]be, let it [G]be
+{end_of_chorus}
+
+{c: repeat twice}
+{chorus}
+{sot}
+e|--0--1--|
+{eot}
+`
+
+func TestParse(t *testing.T) {
+	sheet, err := chordpro.Parse(song)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "Let It Be", sheet.Title)
+	assert.Equal(t, "The Beatles", sheet.Artist)
+	assert.Equal(t, "C", sheet.Key)
+	assert.Equal(t, map[string]string{"tempo": "72"}, sheet.Meta)
+
+	assert.Equal(t, []models.ChordSection{
+		{Type: models.SectionVerse, Lines: []models.ChordLine{
+			{Text: "When I find myself in times of trouble", Chords: []models.ChordPosition{{Offset: 7, Chord: "C"}, {Offset: 22, Chord: "G"}}},
+			{Text: "Mother Mary comes to me", Chords: []models.ChordPosition{{Offset: 0, Chord: "Am"}, {Offset: 12, Chord: "F"}}},
+		}},
+		{Type: models.SectionChorus, Lines: []models.ChordLine{
+			{Text: "Let it be, let it be", Chords: []models.ChordPosition{{Offset: 7, Chord: "Am"}, {Offset: 18, Chord: "G"}}},
+		}},
+		{Type: models.SectionVerse, Lines: []models.ChordLine{{Text: "repeat twice", Comment: true}}},
+		{Type: models.SectionChorus, Lines: []models.ChordLine{}, Repeat: true},
+		{Type: models.SectionTab, Lines: []models.ChordLine{{Text: "e|--0--1--|"}}},
+	}, sheet.Sections)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{"[C", "a [] b", "{title: x", "{capo: two}"} {
+		_, err := chordpro.Parse(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestLyrics(t *testing.T) {
+	sheet, err := chordpro.Parse(song)
+	assert.Nil(t, err)
+
+	text := chordpro.Lyrics(sheet)
+	assert.Equal(t, "When I find myself in times of trouble\nMother Mary comes to me\n\n"+
+		"[Chorus]\nLet it be, let it be\n\n[Chorus]\n", text)
+
+	sections := lyrics.Expand(lyrics.Parse(text))
+	assert.Len(t, sections, 3)
+	assert.Equal(t, []string{"Let it be, let it be"}, sections[2].Lines)
+}
+
+func TestTranspose(t *testing.T) {
+	sheet := models.ChordSheet{Key: "C", Sections: []models.ChordSection{{Lines: []models.ChordLine{{
+		Text:   "a b c d",
+		Chords: []models.ChordPosition{{Chord: "C"}, {Offset: 2, Chord: "Am7"}, {Offset: 4, Chord: "G/B"}, {Offset: 6, Chord: "N.C."}},
+	}}}}}
+
+	chords := func(s models.ChordSheet) []string {
+		var names []string
+		for _, c := range s.Sections[0].Lines[0].Chords {
+			names = append(names, c.Chord)
+		}
+		return names
+	}
+
+	up := chordpro.Transpose(sheet, 2, models.NotationLatin)
+	assert.Equal(t, "D", up.Key)
+	assert.Equal(t, []string{"D", "Bm7", "A/C#", "N.C."}, chords(up))
+
+	// F is a flat key
+	flat := chordpro.Transpose(sheet, -7, models.NotationLatin)
+	assert.Equal(t, "F", flat.Key)
+	assert.Equal(t, []string{"F", "Dm7", "C/E", "N.C."}, chords(flat))
+	assert.Equal(t, []string{"Bb", "Gm7", "F/A", "N.C."}, chords(chordpro.Transpose(sheet, 10, models.NotationLatin)))
+
+	assert.Equal(t, []string{"B", "Gm7", "F/A", "N.C."}, chords(chordpro.Transpose(sheet, 10, models.NotationGerman)))
+	assert.Equal(t, []string{"H", "G#m7", "F#/A#", "N.C."}, chords(chordpro.Transpose(sheet, 11, models.NotationGerman)))
+	assert.Equal(t, []string{"Do", "Lam7", "Sol/Si", "N.C."}, chords(chordpro.Transpose(sheet, 0, models.NotationSolfege)))
+
+	// the source sheet is left alone
+	assert.Equal(t, []string{"C", "Am7", "G/B", "N.C."}, chords(sheet))
+}
+
+func TestText(t *testing.T) {
+	sheet, err := chordpro.Parse("{title: Song}\n{key: G}\n[G]Hi [Cmaj7]there\n[D]Oh[Em]\n\n{soc}\nLa [C]la\n{eoc}\n{c: end}")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "Song\nKey: G\n\n"+
+		"G  Cmaj7\nHi there\nD Em\nOh\n\n"+
+		"Chorus\n   C\nLa la\n\n(end)\n", chordpro.Text(sheet))
+}
+
+func TestHTML(t *testing.T) {
+	sheet, err := chordpro.Parse("{title: <Song>}\n[G]Hi & [C]bye")
+	assert.Nil(t, err)
+
+	out, err := chordpro.HTML(sheet)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(out, "<h1>&lt;Song&gt;</h1>"), out)
+	assert.True(t, strings.Contains(out, `<span class="chunk"><span class="chord">G</span><span class="lyrics">Hi &amp; </span></span>`), out)
+}
@@ -0,0 +1,199 @@
+package chordpro
+
+import (
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"html/template"
+	"strings"
+	"unicode/utf8"
+)
+
+// Text renders the sheet as plain text with the chords on a line above the
+// lyrics they are played over. Lyrics are padded where chords would run into
+// each other, so it reads best in a monospaced font.
+func Text(sheet models.ChordSheet) string {
+	var b strings.Builder
+
+	for _, line := range []string{sheet.Title, sheet.Subtitle, sheet.Artist} {
+		if line != "" {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	var info []string
+	if sheet.Key != "" {
+		info = append(info, "Key: "+sheet.Key)
+	}
+	if sheet.Capo > 0 {
+		info = append(info, fmt.Sprintf("Capo: %d", sheet.Capo))
+	}
+	if len(info) > 0 {
+		b.WriteString(strings.Join(info, "  ") + "\n")
+	}
+
+	for _, s := range sheet.Sections {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if label := sectionLabel(s); label != "" {
+			b.WriteString(label + "\n")
+		}
+
+		for _, line := range s.Lines {
+			if line.Comment {
+				b.WriteString("(" + line.Text + ")\n")
+				continue
+			}
+			if len(line.Chords) == 0 {
+				b.WriteString(line.Text + "\n")
+				continue
+			}
+
+			chords, lyrics := textLine(line)
+			b.WriteString(chords + "\n")
+			if lyrics != "" {
+				b.WriteString(lyrics + "\n")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// sectionLabel is the heading of a section, verses only get one when labeled
+func sectionLabel(s models.ChordSection) string {
+	if s.Label != "" {
+		return s.Label
+	}
+	if s.Type == models.SectionVerse {
+		return ""
+	}
+
+	return header(s)
+}
+
+// textLine lays out the chord line over the lyrics line
+func textLine(line models.ChordLine) (string, string) {
+	var chords, lyrics strings.Builder
+
+	segs := segments(line)
+	for i, seg := range segs {
+		width := utf8.RuneCountInString(seg.Text)
+		// chords but the last are followed by at least one space
+		if seg.Chord != "" && i < len(segs)-1 && width <= utf8.RuneCountInString(seg.Chord) {
+			width = utf8.RuneCountInString(seg.Chord) + 1
+		}
+
+		chords.WriteString(pad(seg.Chord, width))
+		lyrics.WriteString(pad(seg.Text, width))
+	}
+
+	return strings.TrimRight(chords.String(), " "), strings.TrimRight(lyrics.String(), " ")
+}
+
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-utf8.RuneCountInString(s), 0))
+}
+
+// segment is the text from one chord up to the next one
+type segment struct {
+	Chord string
+	Text  string
+}
+
+// segments splits the line at its chords, text before the first chord makes
+// a segment without a chord
+func segments(line models.ChordLine) []segment {
+	runes := []rune(line.Text)
+	at := func(offset int) int {
+		return min(max(offset, 0), len(runes))
+	}
+
+	var segs []segment
+	if len(line.Chords) == 0 || at(line.Chords[0].Offset) > 0 {
+		end := len(runes)
+		if len(line.Chords) > 0 {
+			end = at(line.Chords[0].Offset)
+		}
+		segs = append(segs, segment{Text: string(runes[:end])})
+	}
+
+	for i, c := range line.Chords {
+		start, end := at(c.Offset), len(runes)
+		if i+1 < len(line.Chords) {
+			end = at(line.Chords[i+1].Offset)
+		}
+		segs = append(segs, segment{Chord: c.Chord, Text: string(runes[start:max(start, end)])})
+	}
+
+	return segs
+}
+
+var htmlTemplate = template.Must(template.New("sheet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+.line { margin: 0; white-space: pre-wrap; }
+.chunk { display: inline-block; vertical-align: bottom; }
+.chord { display: block; font-weight: bold; min-height: 1.2em; padding-right: 0.3em; }
+.comment { font-style: italic; }
+section { margin-bottom: 1em; }
+section.chorus { padding-left: 1em; border-left: 2px solid #999; }
+section.tab { font-family: monospace; white-space: pre; }
+</style>
+</head>
+<body>
+<article class="chord-sheet">
+{{with .Title}}<h1>{{.}}</h1>
+{{end}}{{with .Subtitle}}<h2>{{.}}</h2>
+{{end}}{{with .Artist}}<p class="artist">{{.}}</p>
+{{end}}{{with .Key}}<p class="key">Key: {{.}}</p>
+{{end}}{{with .Capo}}<p class="capo">Capo: {{.}}</p>
+{{end}}{{range .Sections}}<section class="{{.Type}}">
+{{with .Label}}<h3>{{.}}</h3>
+{{end}}{{range .Lines}}{{if .Comment}}<p class="line comment">{{.Text}}</p>
+{{else}}<p class="line">{{range .Segments}}<span class="chunk"><span class="chord">{{.Chord}}</span><span class="lyrics">{{.Text}}</span></span>{{end}}</p>
+{{end}}{{end}}</section>
+{{end}}</article>
+</body>
+</html>
+`))
+
+type htmlSheet struct {
+	models.ChordSheet
+	Sections []htmlSection
+}
+
+type htmlSection struct {
+	Type  string
+	Label string
+	Lines []htmlLine
+}
+
+type htmlLine struct {
+	Text     string
+	Comment  bool
+	Segments []segment
+}
+
+// HTML renders the sheet as a standalone HTML page with every chord stacked
+// over the lyrics it is played on
+func HTML(sheet models.ChordSheet) (string, error) {
+	view := htmlSheet{ChordSheet: sheet}
+	for _, s := range sheet.Sections {
+		section := htmlSection{Type: s.Type, Label: sectionLabel(s)}
+		for _, line := range s.Lines {
+			section.Lines = append(section.Lines, htmlLine{Text: line.Text, Comment: line.Comment, Segments: segments(line)})
+		}
+		view.Sections = append(view.Sections, section)
+	}
+
+	var b strings.Builder
+	if err := htmlTemplate.Execute(&b, view); err != nil {
+		return "", fmt.Errorf("can't render chord sheet, err=%w", err)
+	}
+
+	return b.String(), nil
+}
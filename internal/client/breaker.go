@@ -0,0 +1,138 @@
+package client
+
+import (
+	"errors"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// States of the circuit breaker
+const (
+	// BreakerClosed lets every request through
+	BreakerClosed = "closed"
+	// BreakerOpen rejects requests until the cooldown is over
+	BreakerOpen = "open"
+	// BreakerHalfOpen lets a single probe through, its outcome closes or
+	// reopens the circuit
+	BreakerHalfOpen = "half-open"
+)
+
+// breaker opens after threshold consecutive failures and stays open for
+// cooldown. A zero threshold disables it.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	log       *slog.Logger
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	// probing is set while the half-open probe is in flight
+	probing bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration, log *slog.Logger) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		log:       log,
+		now:       time.Now,
+		state:     BreakerClosed,
+	}
+}
+
+// allow reports whether a request may be sent, an open circuit turns half
+// open once the cooldown is over
+func (b *breaker) allow() error {
+	if b.threshold == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !b.now().Before(b.openedAt.Add(b.cooldown)) {
+		b.setState(BreakerHalfOpen)
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+
+	return nil
+}
+
+// success records a request the upstream answered properly
+func (b *breaker) success() {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != BreakerClosed {
+		b.setState(BreakerClosed)
+	}
+}
+
+// failure records a failed request, a failed probe reopens the circuit
+func (b *breaker) failure() {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// release ends a request that said nothing about the upstream health, e.g.
+// one canceled by the caller
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *breaker) setState(state string) {
+	b.log.Warn("circuit breaker state changed",
+		slog.String("from", b.state),
+		slog.String("to", state),
+		slog.Int("failures", b.failures))
+	b.state = state
+}
+
+func (b *breaker) status() models.UpstreamStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := models.UpstreamStatus{State: b.state, Failures: b.failures}
+	if b.state == BreakerOpen {
+		retryAt := b.openedAt.Add(b.cooldown)
+		status.RetryAt = &retryAt
+	}
+
+	return status
+}
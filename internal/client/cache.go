@@ -0,0 +1,192 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"golang.org/x/sync/singleflight"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CacheConfig bounds the details cache
+type CacheConfig struct {
+	// Size is the number of lookups kept, the least recently used one is
+	// dropped first
+	Size int
+	// TTL is how long found details are served from the cache
+	TTL time.Duration
+	// NegativeTTL is how long a 404 is served from the cache, zero doesn't
+	// cache it at all
+	NegativeTTL time.Duration
+}
+
+// Cache keeps the details of recent lookups in front of another client.
+// Concurrent lookups of the same song share a single upstream request.
+type Cache struct {
+	next  ClientInterface
+	cfg   CacheConfig
+	log   *slog.Logger
+	now   func() time.Time
+	group singleflight.Group
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[cacheKey]*list.Element
+	stats   models.CacheStats
+}
+
+type noCacheKey struct{}
+
+// WithoutCache makes the cache fetch fresh details for requests made with the
+// returned context, the cached ones are replaced by them
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+type cacheKey struct {
+	song, group string
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	details   models.Song
+	err       error
+	expiresAt time.Time
+}
+
+func NewCache(next ClientInterface, cfg CacheConfig, log *slog.Logger) *Cache {
+	return &Cache{
+		next:    next,
+		cfg:     cfg,
+		log:     log,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[cacheKey]*list.Element, cfg.Size),
+	}
+}
+
+// GetDetails returns the cached details of the song or fetches them. Only
+// found details and 404 responses are cached, other errors are not.
+func (c *Cache) GetDetails(ctx context.Context, song string, groupName string) (models.Song, error) {
+	key := cacheKey{song: song, group: groupName}
+
+	if ctx.Value(noCacheKey{}) == nil {
+		if entry, ok := c.lookup(key); ok {
+			c.log.Debug("details cache hit", slog.String("song", song), slog.String("group", groupName))
+			return entry.details, entry.err
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// only the closure of the caller that started the request runs
+	var started bool
+	ch := c.group.DoChan(key.song+"\x00"+key.group, func() (any, error) {
+		started = true
+
+		// the shared request must not be canceled by whichever caller
+		// started it, but it keeps that caller's deadline
+		fetchCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithDeadline(fetchCtx, deadline)
+			defer cancel()
+		}
+
+		details, err := c.next.GetDetails(fetchCtx, song, groupName)
+		c.store(key, details, err)
+		return details, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return models.Song{}, ctx.Err()
+	case res := <-ch:
+		if !started {
+			c.mu.Lock()
+			c.stats.Shared++
+			c.mu.Unlock()
+		}
+		details, _ := res.Val.(models.Song)
+		return details, res.Err
+	}
+}
+
+// lookup returns the fresh entry of key and counts the hit
+func (c *Cache) lookup(key cacheKey) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.now().Before(entry.expiresAt) {
+			c.order.MoveToFront(elem)
+			c.stats.Hits++
+			if entry.err != nil {
+				c.stats.NegativeHits++
+			}
+			return *entry, true
+		}
+		c.remove(elem)
+	}
+
+	return cacheEntry{}, false
+}
+
+// store caches the outcome of a lookup if it is worth caching
+func (c *Cache) store(key cacheKey, details models.Song, err error) {
+	ttl := c.cfg.TTL
+	if err != nil {
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound || Temporary(err) {
+			return
+		}
+		ttl = c.cfg.NegativeTTL
+	}
+	if ttl <= 0 || c.cfg.Size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, details: details, err: err, expiresAt: c.now().Add(ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.cfg.Size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// Status returns the state of the wrapped client with the cache counters
+func (c *Cache) Status() models.UpstreamStatus {
+	status := c.next.Status()
+
+	c.mu.Lock()
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	c.mu.Unlock()
+
+	stats.Capacity = c.cfg.Size
+	status.Cache = &stats
+
+	return status
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Provider is a named source of song details
+type Provider struct {
+	Name   string
+	Client ClientInterface
+}
+
+// ProvidersError holds the errors of the providers that were asked, in
+// priority order. Errors another attempt may fix come first.
+type ProvidersError struct {
+	Errs []error
+}
+
+func (e *ProvidersError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ProvidersError) Unwrap() []error {
+	return e.Errs
+}
+
+// Chain asks several providers for the details and merges their answers
+// field by field. A provider that fails or whose circuit is open is skipped.
+type Chain struct {
+	providers []Provider
+	// merge is one of the models.Merge* policies
+	merge string
+	// parallel asks all providers at once instead of one after another
+	parallel bool
+	log      *slog.Logger
+}
+
+func NewChain(providers []Provider, merge string, parallel bool, log *slog.Logger) *Chain {
+	return &Chain{
+		providers: providers,
+		merge:     merge,
+		parallel:  parallel,
+		log:       log,
+	}
+}
+
+// answer is the outcome of asking a provider, asked is false for the ones
+// the chain never got to
+type answer struct {
+	details models.Song
+	err     error
+	asked   bool
+}
+
+// GetDetails returns the details merged from the providers in priority
+// order, Sources names the provider of each field. Asking one after another
+// stops once the merged details are complete.
+func (c *Chain) GetDetails(ctx context.Context, song string, groupName string) (models.Song, error) {
+	answers := make([]answer, len(c.providers))
+
+	if c.parallel {
+		var wg sync.WaitGroup
+		for i, p := range c.providers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				details, err := p.Client.GetDetails(ctx, song, groupName)
+				answers[i] = answer{details: details, err: err, asked: true}
+			}()
+		}
+		wg.Wait()
+	} else {
+		for i, p := range c.providers {
+			details, err := p.Client.GetDetails(ctx, song, groupName)
+			answers[i] = answer{details: details, err: err, asked: true}
+
+			if ctx.Err() != nil {
+				break
+			}
+			if merged, _ := c.combine(answers[:i+1]); c.complete(merged) {
+				break
+			}
+		}
+	}
+
+	merged, answered := c.combine(answers)
+	if !answered {
+		return models.Song{}, c.failure(answers)
+	}
+
+	return merged, nil
+}
+
+// combine merges the successful answers as the policy says and reports
+// whether there was any
+func (c *Chain) combine(answers []answer) (models.Song, bool) {
+	var (
+		merged   models.Song
+		answered bool
+	)
+	for i, a := range answers {
+		if !a.asked || a.err != nil {
+			continue
+		}
+		if answered && c.merge == models.MergeFirst {
+			break
+		}
+
+		name := c.providers[i].Name
+		if !answered {
+			merged.Song, merged.GroupName = a.details.Song, a.details.GroupName
+			merged.Sources = models.FieldSources{}
+			answered = true
+		}
+		if merged.Text == "" && a.details.Text != "" {
+			merged.Text = a.details.Text
+			merged.Sources[models.FieldText] = name
+		}
+		if merged.Link == "" && a.details.Link != "" {
+			merged.Link = a.details.Link
+			merged.Sources[models.FieldLink] = name
+		}
+		if merged.ReleaseDate.IsZero() && !a.details.ReleaseDate.IsZero() {
+			merged.ReleaseDate = a.details.ReleaseDate
+			merged.Sources[models.FieldReleaseDate] = name
+		}
+	}
+
+	return merged, answered
+}
+
+// complete reports whether asking further providers can't change the merged
+// details
+func (c *Chain) complete(merged models.Song) bool {
+	if merged.Sources == nil {
+		return false
+	}
+	if c.merge == models.MergeFirst {
+		return true
+	}
+
+	return merged.Text != "" && merged.Link != "" && !merged.ReleaseDate.IsZero()
+}
+
+// failure builds the error of a chain no provider answered. Open circuits
+// are only reported when every provider had one, so that the caller retries
+// as soon as the first of them closes.
+func (c *Chain) failure(answers []answer) error {
+	var temporary, permanent, open []error
+	for i, a := range answers {
+		if !a.asked {
+			continue
+		}
+
+		err := fmt.Errorf("%s: %w", c.providers[i].Name, a.err)
+		c.log.Warn("provider failed", slog.String("provider", c.providers[i].Name), slog.Any("error", a.err))
+
+		switch {
+		case errors.Is(a.err, ErrCircuitOpen):
+			open = append(open, err)
+		case Temporary(a.err):
+			temporary = append(temporary, err)
+		default:
+			permanent = append(permanent, err)
+		}
+	}
+
+	if len(temporary)+len(permanent) == 0 {
+		return &ProvidersError{Errs: open}
+	}
+
+	return &ProvidersError{Errs: append(temporary, permanent...)}
+}
+
+// Status returns the best state of the providers, with the state of each
+// of them
+func (c *Chain) Status() models.UpstreamStatus {
+	rank := map[string]int{BreakerClosed: 0, BreakerHalfOpen: 1, BreakerOpen: 2}
+
+	status := models.UpstreamStatus{State: BreakerOpen}
+	for i, p := range c.providers {
+		ps := p.Client.Status()
+		status.Providers = append(status.Providers, models.ProviderStatus{
+			Name:     p.Name,
+			State:    ps.State,
+			Failures: ps.Failures,
+			RetryAt:  ps.RetryAt,
+		})
+
+		if rank[ps.State] < rank[status.State] {
+			status.State = ps.State
+		}
+		if i == 0 || ps.Failures < status.Failures {
+			status.Failures = ps.Failures
+		}
+		if ps.RetryAt != nil && (status.RetryAt == nil || ps.RetryAt.Before(*status.RetryAt)) {
+			status.RetryAt = ps.RetryAt
+		}
+	}
+
+	if status.State != BreakerOpen {
+		status.RetryAt = nil
+	}
+
+	return status
+}
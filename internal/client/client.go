@@ -1,71 +1,271 @@
 package client
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/Fyefhqdishka/eff-mobile/internal/models"
-	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
+// ErrInvalidResponse is a 200 response without usable song details, its
+// body is malformed or breaks the upstream contract
+var ErrInvalidResponse = errors.New("invalid response")
+
 type ClientInterface interface {
-	GetDetails(song string, groupName string) (models.Song, error)
+	GetDetails(ctx context.Context, song string, groupName string) (models.Song, error)
+	Status() models.UpstreamStatus
+}
+
+// Config points the client at the upstream and tunes how it deals with a
+// slow or failing one
+type Config struct {
+	// BaseURL is the API root, requests go to its /info
+	BaseURL *url.URL
+	// AuthHeader is set to Token on every request, nothing is sent when Token
+	// is empty
+	AuthHeader string
+	Token      string
+	// TLS replaces the default TLS options when set
+	TLS *tls.Config
+	// Timeout bounds a single attempt, zero leaves it to the caller's context
+	Timeout time.Duration
+	// Retries is the number of attempts after the first one
+	Retries int
+	// Backoff is the base delay between attempts, doubled after each of them
+	// up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// BreakerThreshold consecutive failures open the circuit for
+	// BreakerCooldown, zero disables the breaker
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
 type Client struct {
 	client  *http.Client
-	baseURL string
+	cfg     Config
+	breaker *breaker
 	log     *slog.Logger
 }
 
-func NewClient(baseURL string, log *slog.Logger) *Client {
+func NewClient(cfg Config, log *slog.Logger) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLS != nil {
+		transport.TLSClientConfig = cfg.TLS
+	}
+
 	return &Client{
-		client:  &http.Client{},
-		baseURL: baseURL,
+		client:  &http.Client{Transport: transport},
+		cfg:     cfg,
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown, log),
 		log:     log,
 	}
 }
 
-func (c *Client) GetDetails(song string, groupName string) (models.Song, error) {
-	c.log.Debug("client details song=", song, ", group=", groupName)
+// StatusError is a response with a status other than 200
+type StatusError struct {
+	Code   int
+	Status string
+	// RetryAfter is the delay the upstream asked for, zero if it didn't
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received non-OK response status: %v", e.Status)
+}
+
+// retryable tells whether another attempt may succeed: the upstream is down,
+// overloaded or asked to come back later
+func (e *StatusError) retryable() bool {
+	return e.Code >= http.StatusInternalServerError || e.Code == http.StatusTooManyRequests
+}
+
+// Status returns the state of the circuit breaker
+func (c *Client) Status() models.UpstreamStatus {
+	return c.breaker.status()
+}
+
+// GetDetails fetches the song details. Network errors, timed out attempts,
+// 5xx and 429 responses are retried with jittered exponential backoff or
+// after the delay of a Retry-After header, as long as the context allows.
+func (c *Client) GetDetails(ctx context.Context, song string, groupName string) (models.Song, error) {
+	c.log.Debug("client details", slog.String("song", song), slog.String("group", groupName))
+
+	endpoint := c.cfg.BaseURL.JoinPath("info")
+	endpoint.RawQuery = url.Values{"group": {groupName}, "song": {song}}.Encode()
+	url := endpoint.String()
+
+	for attempt := 0; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			c.log.Warn("request rejected", slog.String("url", url), slog.Any("error", err))
+			return models.Song{}, err
+		}
+
+		details, err := c.attempt(ctx, url)
+		if err == nil {
+			c.breaker.success()
+			c.log.Debug("details fetched", slog.String("url", url), slog.Int("attempt", attempt+1))
+			return details, nil
+		}
+
+		// the caller gave up, that says nothing about the upstream
+		if ctx.Err() != nil {
+			c.breaker.release()
+			return models.Song{}, err
+		}
+
+		retry, failed := classify(err)
+		if failed {
+			c.breaker.failure()
+		} else {
+			c.breaker.success()
+		}
+		if !retry || attempt >= c.cfg.Retries {
+			return models.Song{}, err
+		}
+
+		delay := c.backoff(attempt)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			delay = statusErr.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			c.log.Warn("no time left to retry", slog.String("url", url), slog.Duration("delay", delay))
+			return models.Song{}, err
+		}
+
+		c.log.Warn("attempt failed, retrying",
+			slog.String("url", url),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return models.Song{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes a single request bounded by the per-attempt timeout
+func (c *Client) attempt(ctx context.Context, url string) (models.Song, error) {
+	if c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
 
-	url := fmt.Sprintf("http://%s/info?group=%s&song=%s", c.baseURL, url.QueryEscape(groupName), url.QueryEscape(song))
-	resp, err := c.client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		c.log.Error("failed to make request:", err)
+		c.log.Error("failed to build request", slog.Any("error", err))
 		return models.Song{}, err
 	}
-	defer resp.Body.Close()
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.Token != "" {
+		req.Header.Set(c.cfg.AuthHeader, c.cfg.Token)
+	}
 
-	c.log.Error("server response body:", resp.Body)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.log.Error("failed to make request", slog.Any("error", err))
+		return models.Song{}, err
+	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		c.log.Error("received non-OK response status:", resp.Status)
-		return models.Song{}, fmt.Errorf("received non-OK response status: %v", resp.Status)
+		c.log.Error("received non-OK response status", slog.String("status", resp.Status))
+		return models.Song{}, &StatusError{
+			Code:       resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	detail, err := decodeDetail(resp.Body)
 	if err != nil {
-		c.log.Error("failed to read response body:", err)
+		c.log.Error("failed to decode response body", slog.Any("error", err))
 		return models.Song{}, err
 	}
-	c.log.Debug("server response body:", string(body))
+	c.log.Debug("server response body", slog.Any("detail", detail))
 
-	if len(body) == 0 {
-		c.log.Error("response body is empty")
-		return models.Song{}, fmt.Errorf("empty response body")
-	}
-
-	var songDetail models.Song
-	err = json.Unmarshal(body, &songDetail)
+	song, err := detail.song()
 	if err != nil {
-		c.log.Error("failed to unmarshal response body:", err)
+		c.log.Error("response doesn't match the contract", slog.Any("error", err))
 		return models.Song{}, err
 	}
 
-	c.log.Debug("url=", url)
+	return song, nil
+}
+
+// classify tells whether another attempt may succeed and whether err counts
+// against the health of the upstream. A 4xx other than 429 means the upstream
+// works but won't answer this request.
+func classify(err error) (retry, failed bool) {
+	var (
+		statusErr    *StatusError
+		providersErr *ProvidersError
+	)
+	switch {
+	case errors.As(err, &providersErr):
+		// another attempt may succeed if it may for any of the providers
+		for _, err := range providersErr.Errs {
+			if Temporary(err) {
+				return true, true
+			}
+		}
+		return false, true
+	case errors.As(err, &statusErr):
+		return statusErr.retryable(), statusErr.retryable()
+	case errors.Is(err, ErrInvalidResponse):
+		return false, true
+	}
+
+	// network errors and timed out attempts
+	return true, true
+}
+
+// Temporary tells whether a later request may succeed where the one that
+// failed with err didn't
+func Temporary(err error) bool {
+	retry, _ := classify(err)
+	return retry
+}
+
+// backoff returns the delay before the retry following attempt, half of it
+// fixed and half random so that clients don't retry in lockstep
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.Backoff << min(attempt, 30)
+	if c.cfg.MaxBackoff > 0 && (d > c.cfg.MaxBackoff || d <= 0) {
+		d = c.cfg.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(val string) time.Duration {
+	if val == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(val); err == nil && sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		return max(time.Until(t), 0)
+	}
 
-	return songDetail, nil
+	return 0
 }
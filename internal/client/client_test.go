@@ -0,0 +1,445 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/client"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const details = `{"text": "text", "link": "https://music.example.com/song", "releaseDate": "01.01.2024"}`
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// upstream serves the responses in order, repeating the last one, and counts
+// the requests
+func upstream(t *testing.T, responses ...func(w http.ResponseWriter, r *http.Request)) (func(client.Config) *client.Client, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(hits.Add(1))
+		responses[min(n, len(responses))-1](w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	newClient := func(cfg client.Config) *client.Client {
+		cfg.BaseURL, _ = url.Parse(srv.URL + "/api/v1")
+		return client.NewClient(cfg, testLogger())
+	}
+
+	return newClient, &hits
+}
+
+// retrying retries twice without waiting noticeably
+var retrying = client.Config{Retries: 2, Backoff: time.Millisecond}
+
+func status(code int) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, details)
+}
+
+func TestGetDetailsRequest(t *testing.T) {
+	newClient, _ := upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/info", r.URL.Path)
+		assert.Equal(t, "Group & Co", r.URL.Query().Get("group"))
+		assert.Equal(t, "Song", r.URL.Query().Get("song"))
+		assert.Equal(t, "Token secret", r.Header.Get("X-Api-Key"))
+		ok(w, r)
+	})
+	c := newClient(client.Config{AuthHeader: "X-Api-Key", Token: "Token secret"})
+
+	_, err := c.GetDetails(context.Background(), "Song", "Group & Co")
+	assert.Nil(t, err)
+}
+
+func TestGetDetailsRetriesServerErrors(t *testing.T) {
+	newClient, hits := upstream(t, status(http.StatusServiceUnavailable), status(http.StatusBadGateway), ok)
+	c := newClient(retrying)
+
+	song, err := c.GetDetails(context.Background(), "Song", "Group")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://music.example.com/song", song.Link)
+	assert.Equal(t, int32(3), hits.Load())
+}
+
+func TestGetDetailsGivesUpAfterRetries(t *testing.T) {
+	newClient, hits := upstream(t, status(http.StatusInternalServerError))
+	c := newClient(retrying)
+
+	_, err := c.GetDetails(context.Background(), "Song", "Group")
+
+	var statusErr *client.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusInternalServerError, statusErr.Code)
+	assert.Equal(t, int32(3), hits.Load())
+}
+
+func TestGetDetailsDoesNotRetryClientErrors(t *testing.T) {
+	newClient, hits := upstream(t, status(http.StatusNotFound))
+	c := newClient(retrying)
+
+	_, err := c.GetDetails(context.Background(), "Song", "Group")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), hits.Load())
+
+	newClient, hits = upstream(t, func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "{") })
+	c = newClient(retrying)
+
+	_, err = c.GetDetails(context.Background(), "Song", "Group")
+	assert.ErrorIs(t, err, client.ErrInvalidResponse)
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+func TestGetDetailsNormalizes(t *testing.T) {
+	newClient, _ := upstream(t, body(`{"text": " first\r\nsecond\r\n", "link": "https://music.example.com/song", "releaseDate": "2024-01-02"}`))
+	c := newClient(client.Config{})
+
+	song, err := c.GetDetails(context.Background(), "Song", "Group")
+	assert.Nil(t, err)
+	assert.Equal(t, "first\nsecond", song.Text)
+	assert.Equal(t, "02.01.2024", song.ReleaseDate.String())
+}
+
+func TestGetDetailsContract(t *testing.T) {
+	for name, payload := range map[string]string{
+		"empty":         "",
+		"not an object": `["text"]`,
+		"unknown field": `{"text": "text", "song": "Song"}`,
+		"renamed field": `{"text": "text", "release_date": "2024-01-02"}`,
+		"trailing data": `{"text": "text"} {"text": "text"}`,
+		"no details":    `{"text": " ", "link": ""}`,
+		"bad date":      `{"text": "text", "releaseDate": "2024/01/02"}`,
+		"relative link": `{"text": "text", "link": "/watch?v=1"}`,
+		"ftp link":      `{"text": "text", "link": "ftp://music.example.com/song"}`,
+		"too large":     `{"text": "` + strings.Repeat("a", client.MaxDetailSize) + `"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			newClient, hits := upstream(t, body(payload))
+			c := newClient(retrying)
+
+			_, err := c.GetDetails(context.Background(), "Song", "Group")
+			assert.ErrorIs(t, err, client.ErrInvalidResponse)
+			assert.False(t, client.Temporary(err))
+			assert.Equal(t, int32(1), hits.Load())
+		})
+	}
+}
+
+func TestGetDetailsHonoursRetryAfter(t *testing.T) {
+	tooMany := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+
+	newClient, hits := upstream(t, tooMany, ok)
+	c := newClient(retrying)
+
+	start := time.Now()
+	_, err := c.GetDetails(context.Background(), "Song", "Group")
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(2), hits.Load())
+
+	// a delay past the deadline isn't waited for
+	newClient, hits = upstream(t, tooMany, ok)
+	c = newClient(retrying)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = c.GetDetails(ctx, "Song", "Group")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+func TestGetDetailsAttemptTimeout(t *testing.T) {
+	hang := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}
+
+	newClient, hits := upstream(t, hang, ok)
+	c := newClient(client.Config{Timeout: 50 * time.Millisecond, Retries: 1, Backoff: time.Millisecond})
+
+	_, err := c.GetDetails(context.Background(), "Song", "Group")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	newClient, hits := upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if healthy.Load() {
+			ok(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	c := newClient(client.Config{BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond})
+
+	ctx := context.Background()
+	for range 2 {
+		_, err := c.GetDetails(ctx, "Song", "Group")
+		assert.Error(t, err)
+	}
+
+	breaker := c.Status()
+	assert.Equal(t, client.BreakerOpen, breaker.State)
+	assert.Equal(t, 2, breaker.Failures)
+	assert.NotNil(t, breaker.RetryAt)
+
+	_, err := c.GetDetails(ctx, "Song", "Group")
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+	assert.Equal(t, int32(2), hits.Load())
+
+	// after the cooldown a failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	_, err = c.GetDetails(ctx, "Song", "Group")
+	assert.NotErrorIs(t, err, client.ErrCircuitOpen)
+	assert.Equal(t, client.BreakerOpen, c.Status().State)
+
+	// and a successful one closes it
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	_, err = c.GetDetails(ctx, "Song", "Group")
+	assert.Nil(t, err)
+	assert.Equal(t, client.BreakerClosed, c.Status().State)
+	assert.Equal(t, 0, c.Status().Failures)
+}
+
+func TestCacheHitsAndExpiry(t *testing.T) {
+	newClient, hits := upstream(t, ok)
+	c := client.NewCache(newClient(client.Config{}), client.CacheConfig{Size: 10, TTL: 50 * time.Millisecond}, testLogger())
+
+	ctx := context.Background()
+	for range 2 {
+		song, err := c.GetDetails(ctx, "Song", "Group")
+		assert.Nil(t, err)
+		assert.Equal(t, "https://music.example.com/song", song.Link)
+	}
+	assert.Equal(t, int32(1), hits.Load())
+
+	// another group is another song
+	_, err := c.GetDetails(ctx, "Song", "Other group")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), hits.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = c.GetDetails(ctx, "Song", "Group")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), hits.Load())
+
+	stats := c.Status().Cache
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(3), stats.Misses)
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, client.BreakerClosed, c.Status().State)
+}
+
+func TestCacheNotFound(t *testing.T) {
+	newClient, hits := upstream(t, status(http.StatusNotFound), status(http.StatusInternalServerError))
+	c := client.NewCache(newClient(client.Config{}), client.CacheConfig{Size: 10, TTL: time.Hour, NegativeTTL: time.Hour}, testLogger())
+
+	ctx := context.Background()
+	for range 2 {
+		_, err := c.GetDetails(ctx, "Missing", "Group")
+		var statusErr *client.StatusError
+		assert.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, http.StatusNotFound, statusErr.Code)
+	}
+	assert.Equal(t, int32(1), hits.Load())
+	assert.Equal(t, int64(1), c.Status().Cache.NegativeHits)
+
+	// other errors aren't cached
+	for range 2 {
+		_, err := c.GetDetails(ctx, "Broken", "Group")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(3), hits.Load())
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	newClient, hits := upstream(t, ok)
+	c := client.NewCache(newClient(client.Config{}), client.CacheConfig{Size: 2, TTL: time.Hour}, testLogger())
+
+	ctx := context.Background()
+	for _, song := range []string{"A", "B", "A", "C"} {
+		_, err := c.GetDetails(ctx, song, "Group")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(3), hits.Load())
+
+	// B was used least recently
+	_, _ = c.GetDetails(ctx, "A", "Group")
+	assert.Equal(t, int32(3), hits.Load())
+	_, _ = c.GetDetails(ctx, "B", "Group")
+	assert.Equal(t, int32(4), hits.Load())
+
+	assert.Equal(t, int64(2), c.Status().Cache.Evictions)
+	assert.Equal(t, 2, c.Status().Cache.Entries)
+}
+
+func TestCacheSharesConcurrentLookups(t *testing.T) {
+	release := make(chan struct{})
+	newClient, hits := upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		ok(w, r)
+	})
+	c := client.NewCache(newClient(client.Config{}), client.CacheConfig{Size: 10, TTL: time.Hour}, testLogger())
+
+	const callers = 5
+	errs := make(chan error, callers)
+	for range callers {
+		go func() {
+			_, err := c.GetDetails(context.Background(), "Song", "Group")
+			errs <- err
+		}()
+	}
+
+	assert.Eventually(t, func() bool { return c.Status().Cache.Misses == callers }, time.Second, time.Millisecond)
+	close(release)
+
+	for range callers {
+		assert.Nil(t, <-errs)
+	}
+	assert.Equal(t, int32(1), hits.Load())
+	assert.Equal(t, int64(callers-1), c.Status().Cache.Shared)
+}
+
+func TestCacheBypass(t *testing.T) {
+	newClient, hits := upstream(t, ok)
+	c := client.NewCache(newClient(client.Config{}), client.CacheConfig{Size: 10, TTL: time.Hour}, testLogger())
+
+	ctx := context.Background()
+	_, err := c.GetDetails(ctx, "Song", "Group")
+	assert.Nil(t, err)
+	_, err = c.GetDetails(client.WithoutCache(ctx), "Song", "Group")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), hits.Load())
+
+	// the fresh details are cached
+	_, err = c.GetDetails(ctx, "Song", "Group")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+func body(s string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, s)
+	}
+}
+
+func TestChainMergesByPriority(t *testing.T) {
+	newPrimary, primaryHits := upstream(t, body(`{"text": "primary text", "releaseDate": "01.01.2024"}`))
+	newBackup, backupHits := upstream(t, body(`{"text": "backup text", "link": "https://backup.example.com/song", "releaseDate": "02.02.2022"}`))
+
+	for _, parallel := range []bool{false, true} {
+		c := client.NewChain([]client.Provider{
+			{Name: "primary", Client: newPrimary(client.Config{})},
+			{Name: "backup", Client: newBackup(client.Config{})},
+		}, models.MergePriority, parallel, testLogger())
+
+		song, err := c.GetDetails(context.Background(), "Song", "Group")
+		assert.Nil(t, err)
+		assert.Equal(t, "primary text", song.Text)
+		assert.Equal(t, "https://backup.example.com/song", song.Link)
+		assert.Equal(t, "01.01.2024", song.ReleaseDate.String())
+		assert.Equal(t, models.FieldSources{"text": "primary", "link": "backup", "releasedate": "primary"}, song.Sources)
+	}
+	assert.Equal(t, int32(2), primaryHits.Load())
+	assert.Equal(t, int32(2), backupHits.Load())
+
+	// complete details need no other provider, neither does the first answer
+	for _, merge := range []string{models.MergePriority, models.MergeFirst} {
+		newFull, _ := upstream(t, ok)
+		newUnused, unusedHits := upstream(t, ok)
+		c := client.NewChain([]client.Provider{
+			{Name: "full", Client: newFull(client.Config{})},
+			{Name: "unused", Client: newUnused(client.Config{})},
+		}, merge, false, testLogger())
+
+		_, err := c.GetDetails(context.Background(), "Song", "Group")
+		assert.Nil(t, err)
+		assert.Equal(t, int32(0), unusedHits.Load())
+	}
+}
+
+func TestChainFallsBack(t *testing.T) {
+	newDown, _ := upstream(t, status(http.StatusServiceUnavailable))
+	newBackup, _ := upstream(t, body(`{"text": "backup text"}`))
+	down := newDown(client.Config{BreakerThreshold: 1, BreakerCooldown: time.Hour})
+
+	c := client.NewChain([]client.Provider{
+		{Name: "down", Client: down},
+		{Name: "backup", Client: newBackup(client.Config{})},
+	}, models.MergeFirst, false, testLogger())
+
+	for range 2 {
+		song, err := c.GetDetails(context.Background(), "Song", "Group")
+		assert.Nil(t, err)
+		assert.Equal(t, "backup text", song.Text)
+		assert.Equal(t, models.FieldSources{"text": "backup"}, song.Sources)
+	}
+
+	status := c.Status()
+	assert.Equal(t, client.BreakerClosed, status.State)
+	assert.Nil(t, status.RetryAt)
+	assert.Equal(t, []string{"down", "backup"}, []string{status.Providers[0].Name, status.Providers[1].Name})
+	assert.Equal(t, client.BreakerOpen, status.Providers[0].State)
+	assert.NotNil(t, status.Providers[0].RetryAt)
+}
+
+func TestChainFailure(t *testing.T) {
+	newMissing, _ := upstream(t, status(http.StatusNotFound))
+	newDown, _ := upstream(t, status(http.StatusServiceUnavailable))
+
+	c := client.NewChain([]client.Provider{
+		{Name: "missing", Client: newMissing(client.Config{})},
+		{Name: "down", Client: newDown(client.Config{})},
+	}, models.MergePriority, false, testLogger())
+
+	// the song may still turn up once the second provider is back
+	_, err := c.GetDetails(context.Background(), "Song", "Group")
+	assert.True(t, client.Temporary(err))
+	assert.Equal(t, "down: received non-OK response status: 503 Service Unavailable; missing: received non-OK response status: 404 Not Found", err.Error())
+
+	c = client.NewChain([]client.Provider{
+		{Name: "missing", Client: newMissing(client.Config{})},
+		{Name: "also missing", Client: newMissing(client.Config{})},
+	}, models.MergePriority, true, testLogger())
+
+	_, err = c.GetDetails(context.Background(), "Song", "Group")
+	assert.False(t, client.Temporary(err))
+
+	var statusErr *client.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.Code)
+
+	// with every circuit open the caller waits for the first to close
+	newOpen, _ := upstream(t, status(http.StatusServiceUnavailable))
+	open := newOpen(client.Config{BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	_, _ = open.GetDetails(context.Background(), "Song", "Group")
+
+	c = client.NewChain([]client.Provider{{Name: "open", Client: open}, {Name: "open too", Client: open}}, models.MergePriority, false, testLogger())
+	_, err = c.GetDetails(context.Background(), "Song", "Group")
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+	assert.NotNil(t, c.Status().RetryAt)
+}
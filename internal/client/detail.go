@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// MaxDetailSize bounds the body of an /info response
+const MaxDetailSize = 1 << 20
+
+// SongDetail is the /info response as the upstream contract describes it
+type SongDetail struct {
+	// ReleaseDate is in models.LegacyDateLayout, ISO dates are accepted too
+	ReleaseDate string `json:"releaseDate"`
+	Text        string `json:"text"`
+	Link        string `json:"link"`
+}
+
+// decodeDetail strictly decodes a single SongDetail from body: unknown fields,
+// trailing data and bodies over MaxDetailSize are errors. A field renamed by
+// the upstream, e.g. release_date, fails instead of silently going missing.
+func decodeDetail(body io.Reader) (SongDetail, error) {
+	data, err := io.ReadAll(io.LimitReader(body, MaxDetailSize+1))
+	if err != nil {
+		return SongDetail{}, err
+	}
+	switch {
+	case len(bytes.TrimSpace(data)) == 0:
+		return SongDetail{}, fmt.Errorf("%w: empty response body", ErrInvalidResponse)
+	case len(data) > MaxDetailSize:
+		return SongDetail{}, fmt.Errorf("%w: response body exceeds %d bytes", ErrInvalidResponse, MaxDetailSize)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var detail SongDetail
+	if err := dec.Decode(&detail); err != nil {
+		return SongDetail{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return SongDetail{}, fmt.Errorf("%w: unexpected data after the song detail", ErrInvalidResponse)
+	}
+
+	return detail, nil
+}
+
+// song validates the detail and normalizes it into the song details: line
+// breaks of the text become \n and the release date is parsed. All the
+// problems are reported at once.
+func (d SongDetail) song() (models.Song, error) {
+	var (
+		song     models.Song
+		problems []string
+		err      error
+	)
+
+	song.Text = strings.TrimSpace(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(d.Text))
+
+	song.Link = strings.TrimSpace(d.Link)
+	if song.Link != "" && !validLink(song.Link) {
+		problems = append(problems, fmt.Sprintf("link %q is not an absolute http(s) URL", d.Link))
+	}
+
+	if song.ReleaseDate, err = models.ParseDate(strings.TrimSpace(d.ReleaseDate)); err != nil {
+		problems = append(problems, fmt.Sprintf("releaseDate: %v", err))
+	}
+
+	if len(problems) == 0 && song.Text == "" && song.Link == "" && song.ReleaseDate.IsZero() {
+		problems = append(problems, "no text, link or releaseDate")
+	}
+	if len(problems) > 0 {
+		return models.Song{}, fmt.Errorf("%w: %s", ErrInvalidResponse, strings.Join(problems, "; "))
+	}
+
+	return song, nil
+}
+
+// validLink tells whether link is an absolute http or https URL with a host
+func validLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
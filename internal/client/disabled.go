@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+)
+
+var ErrDisabled = errors.New("enrichment API is disabled")
+
+// UpstreamDisabled is the state reported when enrichment is turned off
+const UpstreamDisabled = "disabled"
+
+// Disabled stands in for the client when enrichment is turned off
+type Disabled struct{}
+
+func (Disabled) GetDetails(ctx context.Context, song string, groupName string) (models.Song, error) {
+	return models.Song{}, ErrDisabled
+}
+
+func (Disabled) Status() models.UpstreamStatus {
+	return models.UpstreamStatus{State: UpstreamDisabled}
+}
@@ -1,16 +1,22 @@
 package config
 
 import (
+	"crypto/rand"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
 type Config struct {
-	DB     DB
-	Server Server
+	// Env is EnvDev or EnvProduction
+	Env        string
+	DB         DB
+	Server     Server
+	Upstream   Upstream
+	Enrichment Enrichment
 }
 
 type DB struct {
@@ -26,8 +32,20 @@ type Server struct {
 	Port        string
 	Timeout     time.Duration
 	IdleTimeout time.Duration
+	// CursorSecret signs the pagination cursors handed out to clients
+	CursorSecret []byte
+	// EphemeralCursorSecret is set when CursorSecret was generated at startup
+	// because none was configured, cursors then break on a restart and
+	// across replicas
+	EphemeralCursorSecret bool
 }
 
+// Environments the service runs in, set by APP_ENV
+const (
+	EnvDev        = "dev"
+	EnvProduction = "production"
+)
+
 // default value for write and read timeouts
 const (
 	DefaultTimeout     = 10 * time.Second
@@ -36,6 +54,7 @@ const (
 
 func LoadFromEnv() (*Config, error) {
 	cfg := &Config{
+		Env: os.Getenv("APP_ENV"),
 		DB: DB{
 			Host: os.Getenv("DB_HOST"),
 			Port: os.Getenv("DB_PORT"),
@@ -49,26 +68,92 @@ func LoadFromEnv() (*Config, error) {
 		},
 	}
 
-	timeout := DefaultTimeout
-	if val := os.Getenv("SRV_TIMEOUT"); val != "" {
-		parsed, err := time.ParseDuration(val)
-		if err != nil {
-			return nil, fmt.Errorf("invalid SRV_TIMEOUT: %v", err)
-		}
-		timeout = parsed
+	if cfg.Env == "" {
+		cfg.Env = EnvProduction
+	}
+	if cfg.Env != EnvDev && cfg.Env != EnvProduction {
+		return nil, fmt.Errorf("invalid APP_ENV: must be %s or %s", EnvDev, EnvProduction)
 	}
 
-	idleTimeout := DefaultIdleTimeout
-	if val := os.Getenv("SRV_IDLE_TIMEOUT"); val != "" {
-		parsed, err := time.ParseDuration(val)
-		if err != nil {
-			return nil, fmt.Errorf("invalid SRV_IDLE_TIMEOUT: %v", err)
-		}
-		idleTimeout = parsed
+	var err error
+	if cfg.Server.Timeout, err = durationEnv("SRV_TIMEOUT", DefaultTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.Server.IdleTimeout, err = durationEnv("SRV_IDLE_TIMEOUT", DefaultIdleTimeout); err != nil {
+		return nil, err
 	}
 
-	cfg.Server.Timeout = timeout
-	cfg.Server.IdleTimeout = idleTimeout
+	if cfg.Upstream, err = loadUpstream(); err != nil {
+		return nil, err
+	}
+	if cfg.Enrichment, err = loadEnrichment(); err != nil {
+		return nil, err
+	}
+
+	// without a configured secret cursors stay valid only until a restart,
+	// which is only good enough for development
+	cfg.Server.CursorSecret = []byte(os.Getenv("SRV_CURSOR_SECRET"))
+	if len(cfg.Server.CursorSecret) == 0 {
+		if cfg.Env != EnvDev {
+			return nil, fmt.Errorf("SRV_CURSOR_SECRET is required outside %s", EnvDev)
+		}
+		cfg.Server.EphemeralCursorSecret = true
+		cfg.Server.CursorSecret = make([]byte, 32)
+		if _, err := rand.Read(cfg.Server.CursorSecret); err != nil {
+			return nil, fmt.Errorf("can't generate cursor secret: %v", err)
+		}
+	}
 
 	return cfg, nil
 }
+
+// durationEnv reads a duration like 1.5s from the environment variable name
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return def, nil
+	}
+
+	parsed, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	if parsed < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative", name)
+	}
+
+	return parsed, nil
+}
+
+// intEnv reads a non-negative integer from the environment variable name
+func intEnv(name string, def int) (int, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return def, nil
+	}
+
+	parsed, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	if parsed < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative", name)
+	}
+
+	return parsed, nil
+}
+
+// boolEnv reads a boolean like true or 0 from the environment variable name
+func boolEnv(name string, def bool) (bool, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return def, nil
+	}
+
+	parsed, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %v", name, err)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+)
+
+// Enrichment configures the pool of workers enriching new songs in the
+// background
+type Enrichment struct {
+	Workers int
+	// BatchSize is the number of pending songs claimed at once
+	BatchSize int
+	// PollInterval is how often the database is checked for due songs
+	PollInterval time.Duration
+	// Lease bounds a single enrichment, after it the song is claimed again
+	Lease time.Duration
+	// MaxAttempts failed attempts mark the song as failed
+	MaxAttempts int
+	// Backoff is the delay after the first failed attempt, it doubles up to
+	// MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Merge is how the details are merged with the fields the user set, one
+	// of models.MergeUserWins, MergeUpstreamWins and MergeFillMissing
+	Merge string
+	// SkipComplete doesn't ask the API for songs created with all the fields
+	SkipComplete bool
+}
+
+// defaults of the enrichment pool, the lease leaves room for all the retries
+// of the client
+const (
+	DefaultEnrichWorkers      = 4
+	DefaultEnrichBatchSize    = 16
+	DefaultEnrichPollInterval = 5 * time.Second
+	DefaultEnrichLease        = time.Minute
+	DefaultEnrichMaxAttempts  = 5
+	DefaultEnrichBackoff      = 10 * time.Second
+	DefaultEnrichMaxBackoff   = 10 * time.Minute
+)
+
+func loadEnrichment() (Enrichment, error) {
+	var (
+		en  = Enrichment{Merge: os.Getenv("ENRICH_MERGE")}
+		err error
+	)
+	if en.Merge == "" {
+		en.Merge = models.MergeUserWins
+	}
+	if en.Workers, err = intEnv("ENRICH_WORKERS", DefaultEnrichWorkers); err != nil {
+		return Enrichment{}, err
+	}
+	if en.BatchSize, err = intEnv("ENRICH_BATCH_SIZE", DefaultEnrichBatchSize); err != nil {
+		return Enrichment{}, err
+	}
+	if en.PollInterval, err = durationEnv("ENRICH_POLL_INTERVAL", DefaultEnrichPollInterval); err != nil {
+		return Enrichment{}, err
+	}
+	if en.Lease, err = durationEnv("ENRICH_LEASE", DefaultEnrichLease); err != nil {
+		return Enrichment{}, err
+	}
+	if en.MaxAttempts, err = intEnv("ENRICH_MAX_ATTEMPTS", DefaultEnrichMaxAttempts); err != nil {
+		return Enrichment{}, err
+	}
+	if en.Backoff, err = durationEnv("ENRICH_BACKOFF", DefaultEnrichBackoff); err != nil {
+		return Enrichment{}, err
+	}
+	if en.MaxBackoff, err = durationEnv("ENRICH_MAX_BACKOFF", DefaultEnrichMaxBackoff); err != nil {
+		return Enrichment{}, err
+	}
+	if en.SkipComplete, err = boolEnv("ENRICH_SKIP_COMPLETE", true); err != nil {
+		return Enrichment{}, err
+	}
+
+	switch {
+	case en.Workers == 0:
+		return Enrichment{}, fmt.Errorf("invalid ENRICH_WORKERS: must be positive")
+	case en.BatchSize == 0:
+		return Enrichment{}, fmt.Errorf("invalid ENRICH_BATCH_SIZE: must be positive")
+	case en.MaxAttempts == 0:
+		return Enrichment{}, fmt.Errorf("invalid ENRICH_MAX_ATTEMPTS: must be positive")
+	case en.PollInterval == 0:
+		return Enrichment{}, fmt.Errorf("invalid ENRICH_POLL_INTERVAL: must be positive")
+	case en.Lease == 0:
+		return Enrichment{}, fmt.Errorf("invalid ENRICH_LEASE: must be positive")
+	case en.Merge != models.MergeUserWins && en.Merge != models.MergeUpstreamWins && en.Merge != models.MergeFillMissing:
+		return Enrichment{}, fmt.Errorf("invalid ENRICH_MERGE: must be %s, %s or %s", models.MergeUserWins, models.MergeUpstreamWins, models.MergeFillMissing)
+	}
+
+	return en, nil
+}
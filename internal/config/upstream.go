@@ -0,0 +1,252 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+)
+
+// Upstream configures the clients of the song enrichment APIs
+type Upstream struct {
+	// Enabled turns enrichment off when false, songs are stored as sent
+	Enabled bool
+	// Providers are the APIs asked in priority order, there is at least one
+	// when enrichment is enabled
+	Providers []Provider
+	// Merge is how the details of several providers are merged, one of the
+	// models.Merge* policies
+	Merge string
+	// Parallel asks all providers at once instead of one after another
+	Parallel bool
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+	// Retries is the number of attempts after the first one
+	Retries int
+	// Backoff is the base delay between attempts, it doubles up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// BreakerFailures consecutive failures open the circuit breaker for
+	// BreakerCooldown, zero disables it
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	// CacheSize lookups are cached for CacheTTL, not found songs for
+	// CacheNegativeTTL. Zero size disables the cache.
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+}
+
+// Provider is an enrichment API
+type Provider struct {
+	// Name tells the providers apart in logs, statuses and field sources
+	Name string
+	// URL is the base URL of the API, /info is resolved against it
+	URL *url.URL
+	// AuthHeader carries Token on every request when Token is set
+	AuthHeader string
+	Token      string
+	// TLS is nil unless a CA, a client certificate or skipping verification
+	// is configured
+	TLS *tls.Config
+}
+
+// DefaultProviderName names the provider configured without
+// UPSTREAM_PROVIDERS
+const DefaultProviderName = "default"
+
+// defaults of the enrichment API client, the retries fit into DefaultTimeout
+const (
+	DefaultUpstreamAuthHeader       = "Authorization"
+	DefaultUpstreamTimeout          = 3 * time.Second
+	DefaultUpstreamRetries          = 2
+	DefaultUpstreamBackoff          = 200 * time.Millisecond
+	DefaultUpstreamMaxBackoff       = 2 * time.Second
+	DefaultUpstreamBreakerFailures  = 5
+	DefaultUpstreamBreakerCooldown  = 30 * time.Second
+	DefaultUpstreamCacheSize        = 1000
+	DefaultUpstreamCacheTTL         = time.Hour
+	DefaultUpstreamCacheNegativeTTL = 5 * time.Minute
+)
+
+func loadUpstream() (Upstream, error) {
+	up := Upstream{Merge: os.Getenv("UPSTREAM_MERGE")}
+	if up.Merge == "" {
+		up.Merge = models.MergePriority
+	}
+
+	var err error
+	if up.Enabled, err = boolEnv("UPSTREAM_ENABLED", true); err != nil {
+		return Upstream{}, err
+	}
+	if up.Parallel, err = boolEnv("UPSTREAM_PARALLEL", false); err != nil {
+		return Upstream{}, err
+	}
+	if up.Timeout, err = durationEnv("UPSTREAM_TIMEOUT", DefaultUpstreamTimeout); err != nil {
+		return Upstream{}, err
+	}
+	if up.Retries, err = intEnv("UPSTREAM_RETRIES", DefaultUpstreamRetries); err != nil {
+		return Upstream{}, err
+	}
+	if up.Backoff, err = durationEnv("UPSTREAM_BACKOFF", DefaultUpstreamBackoff); err != nil {
+		return Upstream{}, err
+	}
+	if up.MaxBackoff, err = durationEnv("UPSTREAM_MAX_BACKOFF", DefaultUpstreamMaxBackoff); err != nil {
+		return Upstream{}, err
+	}
+	if up.BreakerFailures, err = intEnv("UPSTREAM_BREAKER_FAILURES", DefaultUpstreamBreakerFailures); err != nil {
+		return Upstream{}, err
+	}
+	if up.BreakerCooldown, err = durationEnv("UPSTREAM_BREAKER_COOLDOWN", DefaultUpstreamBreakerCooldown); err != nil {
+		return Upstream{}, err
+	}
+	if up.CacheSize, err = intEnv("UPSTREAM_CACHE_SIZE", DefaultUpstreamCacheSize); err != nil {
+		return Upstream{}, err
+	}
+	if up.CacheTTL, err = durationEnv("UPSTREAM_CACHE_TTL", DefaultUpstreamCacheTTL); err != nil {
+		return Upstream{}, err
+	}
+	if up.CacheNegativeTTL, err = durationEnv("UPSTREAM_CACHE_NEGATIVE_TTL", DefaultUpstreamCacheNegativeTTL); err != nil {
+		return Upstream{}, err
+	}
+
+	if up.Merge != models.MergePriority && up.Merge != models.MergeFirst {
+		return Upstream{}, fmt.Errorf("invalid UPSTREAM_MERGE: must be %s or %s", models.MergePriority, models.MergeFirst)
+	}
+
+	// a disabled upstream needs neither an address nor credentials
+	if !up.Enabled {
+		return up, nil
+	}
+
+	names := os.Getenv("UPSTREAM_PROVIDERS")
+	if names == "" {
+		provider, err := loadProvider(DefaultProviderName, "UPSTREAM_")
+		if err != nil {
+			return Upstream{}, err
+		}
+		up.Providers = []Provider{provider}
+
+		return up, nil
+	}
+
+	seen := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !providerName.MatchString(name) {
+			return Upstream{}, fmt.Errorf("invalid UPSTREAM_PROVIDERS: %q is not a name of letters, digits and underscores", name)
+		}
+		if seen[name] {
+			return Upstream{}, fmt.Errorf("invalid UPSTREAM_PROVIDERS: %q is listed twice", name)
+		}
+		seen[name] = true
+
+		provider, err := loadProvider(name, "UPSTREAM_"+strings.ToUpper(name)+"_")
+		if err != nil {
+			return Upstream{}, err
+		}
+		up.Providers = append(up.Providers, provider)
+	}
+
+	return up, nil
+}
+
+var providerName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// loadProvider reads the provider from the variables starting with prefix,
+// e.g. UPSTREAM_BACKUP_URL
+func loadProvider(name, prefix string) (Provider, error) {
+	p := Provider{
+		Name:       name,
+		AuthHeader: os.Getenv(prefix + "AUTH_HEADER"),
+		Token:      os.Getenv(prefix + "TOKEN"),
+	}
+	if p.AuthHeader == "" {
+		p.AuthHeader = DefaultUpstreamAuthHeader
+	}
+
+	var err error
+	if p.URL, err = upstreamURL(prefix+"URL", os.Getenv(prefix+"URL")); err != nil {
+		return Provider{}, err
+	}
+	if p.TLS, err = upstreamTLS(prefix + "TLS_"); err != nil {
+		return Provider{}, err
+	}
+	if p.TLS != nil && p.URL.Scheme != "https" {
+		return Provider{}, fmt.Errorf("invalid %sURL: TLS options need an https URL", prefix)
+	}
+
+	return p, nil
+}
+
+// upstreamURL parses and checks the base URL of the API read from the
+// variable name
+func upstreamURL(name, val string) (*url.URL, error) {
+	if val == "" {
+		return nil, fmt.Errorf("%s is required, set UPSTREAM_ENABLED=false to run without enrichment", name)
+	}
+
+	u, err := url.Parse(val)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid %s: scheme must be http or https", name)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid %s: host is missing", name)
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return nil, fmt.Errorf("invalid %s: query, fragment and credentials are not allowed, use a token", name)
+	}
+
+	return u, nil
+}
+
+// upstreamTLS builds the TLS options from the variables starting with
+// prefix, e.g. UPSTREAM_TLS_CA_FILE, nil when none is set
+func upstreamTLS(prefix string) (*tls.Config, error) {
+	caFile := os.Getenv(prefix + "CA_FILE")
+	certFile := os.Getenv(prefix + "CERT_FILE")
+	keyFile := os.Getenv(prefix + "KEY_FILE")
+	insecure, err := boolEnv(prefix+"INSECURE_SKIP_VERIFY", false)
+	if err != nil {
+		return nil, err
+	}
+
+	if caFile == "" && certFile == "" && keyFile == "" && !insecure {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sCA_FILE: %v", prefix, err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid %sCA_FILE: no PEM certificates in %s", prefix, caFile)
+		}
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("%sCERT_FILE and %sKEY_FILE must be set together", prefix, prefix)
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sCERT_FILE or %sKEY_FILE: %v", prefix, prefix, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
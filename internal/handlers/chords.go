@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/chordpro"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Formats a chord sheet can be rendered in
+const (
+	chordsFormatJSON = "json"
+	chordsFormatText = "text"
+	chordsFormatHTML = "html"
+)
+
+// GetSongChords returns the chord sheet of a song written in ChordPro
+// @Summary Get the chord sheet of a song
+// @Description Parses the ChordPro text of the song, transposes it and renders it as JSON with chords positioned at character offsets of the lyric lines, as plain text with chords above the lyrics or as an HTML page. Sharps or flats follow the resulting {key}, sheets without a key keep the accidentals of each chord.
+// @Tags songs
+// @Produce  json
+// @Produce  plain
+// @Produce  html
+// @Param id path int true "Song Id"
+// @Param transpose query int false "Semitones to transpose by, -11 to 11, e.g. 2 or -3 (a literal + must be sent as %2B)"
+// @Param notation query string false "Chord notation" Enums(latin, german, solfege) default(latin)
+// @Param format query string false "Response format" Enums(json, text, html) default(json)
+// @Success 200 {object} models.ChordSheet "Chord sheet"
+// @Failure 400 {object} Response "Invalid query parameters"
+// @Failure 404 {object} Response "Song not found or not written in ChordPro"
+// @Failure 422 {object} Response "Transpose or notation out of range"
+// @Failure 500 {object} Response "Failed to get chords"
+// @Router /songs/{id}/chords [get]
+func (h *Handlers) GetSongChords(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	// an unencoded + in the query string arrives as a space
+	transpose := 0
+	if val := strings.TrimPrefix(strings.TrimSpace(query.Get("transpose")), "+"); val != "" {
+		if transpose, err = strconv.Atoi(val); err != nil {
+			h.response(w, SendError(codeBadRequest, "invalid transpose parameter"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	notation := strings.ToLower(query.Get("notation"))
+	if notation == "" {
+		notation = models.NotationLatin
+	}
+
+	format := strings.ToLower(query.Get("format"))
+	switch format {
+	case "":
+		format = chordsFormatJSON
+	case chordsFormatJSON, chordsFormatText, chordsFormatHTML:
+	default:
+		h.response(w, SendError(codeBadRequest, fmt.Sprintf("format must be %q, %q or %q", chordsFormatJSON, chordsFormatText, chordsFormatHTML)), http.StatusBadRequest)
+		return
+	}
+
+	sheet, err := h.Service.Chords(r.Context(), id, transpose, notation)
+	if err != nil {
+		h.fail(w, err, "can't get chords")
+		return
+	}
+
+	switch format {
+	case chordsFormatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, chordpro.Text(sheet))
+	case chordsFormatHTML:
+		page, err := chordpro.HTML(sheet)
+		if err != nil {
+			h.fail(w, err, "can't render chords")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, page)
+	default:
+		h.response(w, SendSuccess(sheet), http.StatusOK)
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"strings"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorCodec turns keyset positions into opaque tokens. Tokens are signed so
+// clients can't forge positions or sort orders the seek query didn't expect,
+// and bound to the filter they were issued for.
+type cursorCodec struct {
+	secret []byte
+}
+
+// cursorPayload is the signed part of a cursor token
+type cursorPayload struct {
+	// Filter is the filterKey of the listing the cursor continues
+	Filter      string      `json:"f"`
+	Sort        string      `json:"s,omitempty"`
+	ID          int         `json:"i"`
+	Song        string      `json:"n,omitempty"`
+	GroupName   string      `json:"g,omitempty"`
+	ReleaseDate models.Date `json:"d"`
+}
+
+// Encode returns the token of cursor continuing the listing of filter
+func (c cursorCodec) Encode(cursor models.SongCursor, filter models.SongFilter) (string, error) {
+	data, err := json.Marshal(cursorPayload{
+		Filter:      filterKey(filter),
+		Sort:        formatSort(cursor.Sort),
+		ID:          cursor.ID,
+		Song:        cursor.Song,
+		GroupName:   cursor.GroupName,
+		ReleaseDate: cursor.ReleaseDate.In(models.ISODateLayout),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload)), nil
+}
+
+// Decode verifies token and returns the cursor it holds along with the
+// filterKey of the listing it continues
+func (c cursorCodec) Decode(token string) (models.SongCursor, string, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return models.SongCursor{}, "", errInvalidCursor
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, c.sign(payload)) {
+		return models.SongCursor{}, "", errInvalidCursor
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return models.SongCursor{}, "", errInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return models.SongCursor{}, "", errInvalidCursor
+	}
+
+	sort, err := songSort(p.Sort)
+	if err != nil {
+		return models.SongCursor{}, "", errInvalidCursor
+	}
+
+	return models.SongCursor{
+		Sort:        sort,
+		ID:          p.ID,
+		Song:        p.Song,
+		GroupName:   p.GroupName,
+		ReleaseDate: p.ReleaseDate,
+	}, p.Filter, nil
+}
+
+func (c cursorCodec) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// filterKey identifies a listing by its filter and sort order, names are
+// lowercased as they are matched case-insensitively. The page size isn't part
+// of it, it may change between pages.
+func filterKey(filter models.SongFilter) string {
+	match := filter.Match
+	if match == "" {
+		match = models.MatchExact
+	}
+
+	data, _ := json.Marshal([]any{
+		filter.ID,
+		filter.GroupID,
+		strings.ToLower(filter.GroupName),
+		strings.ToLower(filter.Song),
+		match,
+		filter.ReleaseDate.In(models.ISODateLayout).String(),
+		filter.ReleasedFrom.In(models.ISODateLayout).String(),
+		filter.ReleasedTo.In(models.ISODateLayout).String(),
+		filter.Year,
+		formatSort(filter.Sort),
+	})
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+// formatSort is the inverse of songSort
+func formatSort(sort []models.SortField) string {
+	keys := make([]string, 0, len(sort))
+	for _, f := range sort {
+		if f.Desc {
+			keys = append(keys, "-"+f.Field)
+			continue
+		}
+		keys = append(keys, f.Field)
+	}
+
+	return strings.Join(keys, ",")
+}
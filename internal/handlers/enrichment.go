@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// GetSongEnrichment returns the state of the background enrichment of a song
+// @Summary Get the enrichment status of a song
+// @Description New songs are enriched from the music info API in the background. A pending song waits for a worker until next_attempt_at, a failed one ran out of attempts or got an answer retrying won't change, a skipped one was stored while enrichment was turned off or with all the fields already set.
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Success 200 {object} models.Enrichment "Enrichment status"
+// @Failure 400 {object} Response "Invalid id"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 500 {object} Response "Failed to get enrichment status"
+// @Router /songs/{id}/enrichment [get]
+func (h *Handlers) GetSongEnrichment(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.Service.Enrichment(r.Context(), id)
+	if err != nil {
+		h.fail(w, err, "can't get enrichment status")
+		return
+	}
+
+	h.response(w, SendSuccess(state), http.StatusOK)
+}
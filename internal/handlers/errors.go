@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+)
+
+// Machine-readable error codes returned in Response.Code
+const (
+	codeBadRequest           = "bad_request"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codePayloadTooLarge      = "payload_too_large"
+	codeNotFound             = "not_found"
+	codeConflict             = "conflict"
+	codeValidation           = "validation_failed"
+	codeUpstream             = "upstream_failed"
+	codePageOutOfRange       = "page_out_of_range"
+	codeTimeout              = "timeout"
+	codeCanceled             = "client_closed_request"
+	codeInternal             = "internal_error"
+)
+
+// statusClientClosedRequest is the non-standard status used when the caller
+// went away before the response was ready.
+const statusClientClosedRequest = 499
+
+type errorMapping struct {
+	target error
+	status int
+	code   string
+}
+
+// errorMappings is checked in order, the first matching target wins. Context
+// errors go first so a timed out upstream call is reported as a timeout.
+var errorMappings = []errorMapping{
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, codeTimeout},
+	{context.Canceled, statusClientClosedRequest, codeCanceled},
+	{models.ErrNotFound, http.StatusNotFound, codeNotFound},
+	{models.ErrPageOutOfRange, http.StatusNotFound, codePageOutOfRange},
+	{models.ErrConflict, http.StatusConflict, codeConflict},
+	{models.ErrValidation, http.StatusUnprocessableEntity, codeValidation},
+	{models.ErrUpstream, http.StatusBadGateway, codeUpstream},
+}
+
+// fail maps err to an HTTP status and error code and writes the error
+// response. Details of unexpected errors are only logged, the client gets
+// the fallback message.
+func (h *Handlers) fail(w http.ResponseWriter, err error, fallback string) {
+	for _, m := range errorMappings {
+		if !errors.Is(err, m.target) {
+			continue
+		}
+
+		msg := err.Error()
+		if m.status >= http.StatusInternalServerError {
+			h.log.Error(fallback, slog.Any("error", err))
+			msg = fallback
+		} else {
+			h.log.Warn(fallback, slog.Any("error", err))
+		}
+
+		h.response(w, SendError(m.code, msg), m.status)
+		return
+	}
+
+	h.log.Error(fallback, slog.Any("error", err))
+	h.response(w, SendError(codeInternal, fallback), http.StatusInternalServerError)
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"net/http"
+)
+
+// GetGroups returns a list of groups
+// @Summary Get all groups
+// @Description Returns groups ordered by name with pagination
+// @Tags groups
+// @Produce  json
+// @Param limit query int false "Limit" default(10)
+// @Param offset query int false "Offset"
+// @Success 200 {array} models.Group "Array of groups"
+// @Failure 400 {object} Response "Invalid query parameters"
+// @Failure 500 {object} Response "Failed to get groups"
+// @Router /groups [get]
+func (h *Handlers) GetGroups(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := h.limitOffset(w, r)
+	if !ok {
+		return
+	}
+
+	groups, err := h.Groups.Get(r.Context(), limit, offset)
+	if err != nil {
+		h.fail(w, err, "can't get groups")
+		return
+	}
+
+	h.response(w, SendSuccess(groups), http.StatusOK)
+}
+
+// GetGroup returns a single group
+// @Summary Get a group by id
+// @Tags groups
+// @Produce  json
+// @Param id path int true "Group Id"
+// @Success 200 {object} models.Group "Group"
+// @Failure 400 {object} Response "Invalid group id"
+// @Failure 404 {object} Response "Group not found"
+// @Failure 500 {object} Response "Failed to get group"
+// @Router /groups/{id} [get]
+func (h *Handlers) GetGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid group id"), http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.Groups.GetByID(r.Context(), id)
+	if err != nil {
+		h.fail(w, err, "can't get group")
+		return
+	}
+
+	h.response(w, SendSuccess(group), http.StatusOK)
+}
+
+// CreateGroup creates a group
+// @Summary Create a new group
+// @Tags groups
+// @Accept  json
+// @Produce  json
+// @Param group body models.Group true "Group name"
+// @Success 201 {object} models.Group "Created group"
+// @Failure 400 {object} Response "Invalid input"
+// @Failure 409 {object} Response "Group already exists"
+// @Failure 422 {object} Response "Validation failed"
+// @Failure 500 {object} Response "Failed to create group"
+// @Router /groups [post]
+func (h *Handlers) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var group models.Group
+
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		h.response(w, SendError(codeBadRequest, "Can't decode json body"), http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.Groups.Create(r.Context(), group.Name)
+	if err != nil {
+		h.fail(w, err, "can't create group")
+		return
+	}
+
+	h.response(w, SendSuccess(group), http.StatusCreated)
+}
+
+// RenameGroup renames a group for all of its songs
+// @Summary Rename a group
+// @Description Renames the group for all of its songs. If another group already has the name, the request fails with 409 unless on_conflict is "merge", in which case the songs are moved into the existing group and this group is removed. To move a single song use PUT /songs/{id}/group.
+// @Tags groups
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Group Id"
+// @Param rename body models.GroupRename true "New group name and conflict handling"
+// @Success 200 {object} models.Group "Renamed or merged group"
+// @Failure 400 {object} Response "Invalid input"
+// @Failure 404 {object} Response "Group not found"
+// @Failure 409 {object} Response "Group name already taken"
+// @Failure 422 {object} Response "Validation failed"
+// @Failure 500 {object} Response "Failed to rename group"
+// @Router /groups/{id} [put]
+func (h *Handlers) RenameGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid group id"), http.StatusBadRequest)
+		return
+	}
+
+	var rename models.GroupRename
+
+	if err := json.NewDecoder(r.Body).Decode(&rename); err != nil {
+		h.response(w, SendError(codeBadRequest, "Can't decode json body"), http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.Groups.Rename(r.Context(), id, rename)
+	if err != nil {
+		h.fail(w, err, "can't rename group")
+		return
+	}
+
+	h.response(w, SendSuccess(group), http.StatusOK)
+}
+
+// DeleteGroup deletes a group with all of its songs
+// @Summary Delete a group
+// @Description Deletes the group together with all of its songs
+// @Tags groups
+// @Produce  json
+// @Param id path int true "Group Id"
+// @Success 200 {integer} int "Deleted group id"
+// @Failure 400 {object} Response "Invalid group id"
+// @Failure 404 {object} Response "Group not found"
+// @Failure 500 {object} Response "Failed to delete group"
+// @Router /groups/{id} [delete]
+func (h *Handlers) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid group id"), http.StatusBadRequest)
+		return
+	}
+
+	id, err = h.Groups.Delete(r.Context(), id)
+	if err != nil {
+		h.fail(w, err, "can't delete group")
+		return
+	}
+
+	h.response(w, SendSuccess(id), http.StatusOK)
+}
+
+// GetGroupSongs returns the songs of a group
+// @Summary Get songs of a group
+// @Tags groups
+// @Produce  json
+// @Param id path int true "Group Id"
+// @Param limit query int false "Limit" default(10)
+// @Param offset query int false "Offset"
+// @Param date_format query string false "Layout of returned dates" Enums(legacy, iso) default(legacy)
+// @Success 200 {array} models.Song "Array of Song's, meta describes the page"
+// @Header 200 {string} Link "first, prev, next and last pages"
+// @Failure 400 {object} Response "Invalid input"
+// @Failure 404 {object} Response "Group not found"
+// @Failure 500 {object} Response "Failed to get songs"
+// @Router /groups/{id}/songs [get]
+func (h *Handlers) GetGroupSongs(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid group id"), http.StatusBadRequest)
+		return
+	}
+
+	layout, err := dateLayout(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, ok := h.limitOffset(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.Groups.Songs(r.Context(), id, limit, offset)
+	if err != nil {
+		h.fail(w, err, "can't get group songs")
+		return
+	}
+
+	resp := SendSuccess(formatDates(page.Songs, layout))
+	resp.Meta = paginate(w, r, Meta{
+		Total:   page.Total,
+		Limit:   limit,
+		Offset:  offset,
+		HasNext: page.HasNext,
+	}, offsetPage(limit))
+
+	h.response(w, resp, http.StatusOK)
+}
+
+// limitOffset parses the limit and offset query parameters, writing a 400
+// response and returning false when they are invalid
+func (h *Handlers) limitOffset(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	limit, err := queryInt(r, "limit", 10)
+	if err != nil || limit < 1 {
+		h.response(w, SendError(codeBadRequest, "Invalid limit parameter"), http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		h.response(w, SendError(codeBadRequest, "Invalid offset parameter"), http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return limit, offset, true
+}
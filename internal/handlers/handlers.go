@@ -2,46 +2,57 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/lyrics"
 	"github.com/Fyefhqdishka/eff-mobile/internal/models"
 	"github.com/Fyefhqdishka/eff-mobile/internal/service"
+	"github.com/gorilla/mux"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type Handlers struct {
 	log     *slog.Logger
 	Service service.ServiceInterface
+	Groups  service.GroupServiceInterface
+	cursors cursorCodec
 }
 
-func NewHandlers(log *slog.Logger, service service.ServiceInterface) *Handlers {
+func NewHandlers(log *slog.Logger, service service.ServiceInterface, groups service.GroupServiceInterface, cursorSecret []byte) *Handlers {
 	return &Handlers{
 		log:     log,
 		Service: service,
+		Groups:  groups,
+		cursors: cursorCodec{secret: cursorSecret},
 	}
 }
 
 // @Summary Create a new song
-// @Description Creates a new song with the given details
+// @Description Stores a new song with the given details right away. The missing text, link and release date are filled in from the music info API in the background, see GET /songs/{id}/enrichment.
 // @Tags songs
 // @Accept  json
 // @Produce  json
 // @Param song body models.Song true "Song details"
-// @Success 200 {object} models.Song "Created song"
+// @Success 201 {object} models.Song "Created song"
 // @Failure 400 {object} Response
+// @Failure 422 {object} Response "Validation failed"
 // @Failure 500 {object} Response
 // @Router /songs [post]
 func (h *Handlers) Create(w http.ResponseWriter, r *http.Request) {
 	var song models.Song
 
 	if err := json.NewDecoder(r.Body).Decode(&song); err != nil {
-		h.response(w, SendError("Can't decode json body"), http.StatusBadRequest)
+		h.response(w, SendError(codeBadRequest, "Can't decode json body"), http.StatusBadRequest)
 		return
 	}
 
-	song, err := h.Service.Create(song)
+	song, err := h.Service.Create(r.Context(), song)
 	if err != nil {
-		h.response(w, SendError("Can't create song"), http.StatusInternalServerError)
+		h.fail(w, err, "Can't create song")
 		return
 	}
 
@@ -53,175 +64,624 @@ func (h *Handlers) Create(w http.ResponseWriter, r *http.Request) {
 // @Tags songs
 // @Accept  json
 // @Produce  json
+// @Param id path int true "Song Id"
 // @Param song body models.Song true "Song details"
 // @Success 200 {object} models.Song "Updates song"
 // @Failure 400 {object} Response "Invalid input"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 409 {object} Response "Body id differs from path id"
+// @Failure 422 {object} Response "Validation failed"
 // @Failure 500 {object} Response "Failed to update song"
 // @Router /songs/{id} [put]
 func (h *Handlers) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid song id"), http.StatusBadRequest)
+		return
+	}
+
 	var song models.Song
 
 	if err := json.NewDecoder(r.Body).Decode(&song); err != nil {
-		h.response(w, SendError("Can't decode json body"), http.StatusBadRequest)
+		h.response(w, SendError(codeBadRequest, "Can't decode json body"), http.StatusBadRequest)
+		return
+	}
+
+	if song.ID != 0 && song.ID != id {
+		h.fail(w, fmt.Errorf("%w: body id %d does not match path id %d", models.ErrConflict, song.ID, id), "can't update song")
 		return
 	}
+	song.ID = id
 
-	success, err := h.Service.Update(song)
+	success, err := h.Service.Update(r.Context(), song)
 	if err != nil {
-		h.response(w, SendError("can't update song"), http.StatusInternalServerError)
+		h.fail(w, err, "can't update song")
 		return
 	}
 
 	h.response(w, SendSuccess(success), http.StatusOK)
 }
 
-// @Summary Delete a song
-// @Description Deleted a song
+// Patch partially updates a song
+// @Summary Partially update a song
+// @Description Updates only the supplied fields. Accepts RFC 7396 merge patch (application/merge-patch+json or application/json) and RFC 6902 JSON patch (application/json-patch+json) with add, replace and remove operations. A null or removed text, link or releasedate clears the field, a cleared text_format falls back to plain.
 // @Tags songs
 // @Accept  json
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
 // @Produce  json
-// @Param song body models.Song true "Song details"
-// @Success 200 {object} models.Song "Updates song"
+// @Param id path int true "Song Id"
+// @Param patch body object true "Merge patch or JSON patch document"
+// @Success 200 {object} models.Song "Updated song"
 // @Failure 400 {object} Response "Invalid input"
-// @Failure 500 {object} Response "Failed to update song"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 409 {object} Response "Body id differs from path id"
+// @Failure 415 {object} Response "Unsupported patch format"
+// @Failure 422 {object} Response "Invalid patch"
+// @Failure 500 {object} Response "Failed to patch song"
+// @Router /songs/{id} [patch]
+func (h *Handlers) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid song id"), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "Can't read request body"), http.StatusBadRequest)
+		return
+	}
+
+	patch, err := parsePatch(r, body, id)
+	if errors.Is(err, errUnsupportedMediaType) {
+		h.response(w, SendError(codeUnsupportedMediaType, err.Error()), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		h.fail(w, err, "can't patch song")
+		return
+	}
+
+	song, err := h.Service.Patch(r.Context(), id, patch)
+	if err != nil {
+		h.fail(w, err, "can't patch song")
+		return
+	}
+
+	h.response(w, SendSuccess(song), http.StatusOK)
+}
+
+// SetSongGroup moves a song to another group
+// @Summary Move a song to another group
+// @Description Reassigns only this song to the group with the given name, creating the group if needed. Other songs of the previous group are not affected. To rename a group for all of its songs use PUT /groups/{id}.
+// @Tags songs
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Param group body models.Group true "Target group name"
+// @Success 200 {object} models.Song "Updated song"
+// @Failure 400 {object} Response "Invalid input"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 422 {object} Response "Validation failed"
+// @Failure 500 {object} Response "Failed to move song"
+// @Router /songs/{id}/group [put]
+func (h *Handlers) SetSongGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid song id"), http.StatusBadRequest)
+		return
+	}
+
+	var group models.Group
+
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		h.response(w, SendError(codeBadRequest, "Can't decode json body"), http.StatusBadRequest)
+		return
+	}
+
+	song, err := h.Service.Patch(r.Context(), id, models.SongPatch{GroupName: &group.Name})
+	if err != nil {
+		h.fail(w, err, "can't move song")
+		return
+	}
+
+	h.response(w, SendSuccess(song), http.StatusOK)
+}
+
+// @Summary Delete a song
+// @Description Deleted a song
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Success 200 {integer} int "Deleted song id"
+// @Failure 400 {object} Response "Invalid song id"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 500 {object} Response "Failed to delete song"
 // @Router /songs/{id} [delete]
 func (h *Handlers) Delete(w http.ResponseWriter, r *http.Request) {
-	var song models.Song
-
-	if err := json.NewDecoder(r.Body).Decode(&song); err != nil {
-		h.response(w, SendError("Can't decode json body"), http.StatusBadRequest)
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid song id"), http.StatusBadRequest)
 		return
 	}
 
-	id, err := h.Service.Delete(song)
+	id, err = h.Service.Delete(r.Context(), id)
 	if err != nil {
-		h.response(w, SendError("can't delete this song"), http.StatusInternalServerError)
+		h.fail(w, err, "can't delete this song")
 		return
 	}
 
 	h.response(w, SendSuccess(id), http.StatusOK)
 }
 
+// GetByID returns a single Song
+// @Summary Get a song by id
+// @Description Returns the song with the given id
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Param date_format query string false "Layout of returned dates" Enums(legacy, iso) default(legacy)
+// @Success 200 {object} models.Song "Song"
+// @Failure 400 {object} Response "Invalid song id"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 500 {object} Response "Failed to get song"
+// @Router /songs/{id} [get]
+func (h *Handlers) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid song id"), http.StatusBadRequest)
+		return
+	}
+
+	layout, err := dateLayout(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	song, err := h.Service.GetByID(r.Context(), id)
+	if err != nil {
+		h.fail(w, err, "can't get song")
+		return
+	}
+	song.ReleaseDate = song.ReleaseDate.In(layout)
+
+	h.response(w, SendSuccess(song), http.StatusOK)
+}
+
 // Get returns a list of Song's
 // @Summary Get all Song's from the storage
-// @Description Returns a list of all songs with optional filtering and pagination
+// @Description Returns a list of all songs with optional filtering and pagination. Dates are accepted as 02.01.2006 or ISO-8601 (2006-01-02). With match=fuzzy song and group_name tolerate typos, results are ordered by similarity unless sort is given and carry a score.
 // @Tags songs
 // @Produce  json
-// @Param limit query int false "Limit"
+// @Param limit query int false "Limit" default(10)
 // @Param offset query int false "Offset"
 // @Param id query int false "Song Id"
 // @Param song query string false "Song title"
 // @Param group_name query string false "Group name"
-// @Param releasedate query string false "Song release date in format 02.01.2006"
-// @Success 200 {array} models.Song "Array of Song's"
-// @Failure 400 {object} Response "Invalid query parameters"
+// @Param match query string false "How song and group_name are matched, case-insensitively" Enums(exact, prefix, contains, fuzzy) default(exact)
+// @Param cursor query string false "next_cursor of the previous page, continues the listing in its sort order instead of offset. The filters must stay the same"
+// @Param sort query string false "Comma separated sort keys out of id, song, group_name and releasedate, - prefix for descending. Ties are broken by id, songs without a release date come last" example(-releasedate,group_name,song)
+// @Param releasedate query string false "Exact release date"
+// @Param released_from query string false "Released on or after this date"
+// @Param released_to query string false "Released on or before this date"
+// @Param year query int false "Release year"
+// @Param date_format query string false "Layout of returned dates" Enums(legacy, iso) default(legacy)
+// @Success 200 {array} models.Song "Array of Song's, meta describes the page and next_cursor points to the next one"
+// @Header 200 {string} Link "first, prev, next and last pages"
+// @Failure 400 {object} Response "Invalid query parameters or cursor"
+// @Failure 422 {object} Response "Contradicting filters, fuzzy match without a name, cursor combined with offset or issued for another filter or sort order"
 // @Failure 500 {object} Response "Failed to get Song's"
 // @Router /songs [get]
 func (h *Handlers) Get(w http.ResponseWriter, r *http.Request) {
-	groupName := r.URL.Query().Get("group_name")
-	songName := r.URL.Query().Get("song")
-	releaseDate := r.URL.Query().Get("releasedate")
-	limit := r.URL.Query().Get("limit")
-	offset := r.URL.Query().Get("offset")
-	songIDStr := r.URL.Query().Get("id")
-	var songID int
-	if songIDStr != "" {
-		var err error
-		songID, err = strconv.Atoi(songIDStr)
-		if err != nil {
-			http.Error(w, "invalid songID", http.StatusBadRequest)
-			return
-		}
+	filter, err := songFilter(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
+		return
 	}
 
-	limitInt := 10
-	offsetInt := 0
+	layout, err := dateLayout(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
+		return
+	}
 
-	if limit != "" {
-		var err error
-		limitInt, err = strconv.Atoi(limit)
+	var ok bool
+	filter.Limit, filter.Offset, ok = h.limitOffset(w, r)
+	if !ok {
+		return
+	}
+
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		cursor, key, err := h.cursors.Decode(token)
 		if err != nil {
-			h.response(w, SendError("Invalid limit parameter"), http.StatusBadRequest)
+			h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
 			return
 		}
-	}
-	if offset != "" {
-		var err error
-		offsetInt, err = strconv.Atoi(offset)
-		if err != nil {
-			h.response(w, SendError("Invalid offset parameter"), http.StatusBadRequest)
+
+		// the sort order is part of the cursor and can be left out of the next requests
+		if len(filter.Sort) == 0 {
+			filter.Sort = cursor.Sort
+		}
+		// a cursor seeks on the keys of its own listing only
+		if filterKey(filter) != key {
+			h.fail(w, fmt.Errorf("%w: cursor was issued for another filter or sort order", models.ErrValidation), "can't continue the listing")
 			return
 		}
+		filter.After = &cursor
+	}
+
+	page, err := h.Service.Get(r.Context(), filter)
+	if err != nil {
+		h.fail(w, err, "can't get all songs")
+		return
+	}
+
+	next, err := h.nextCursor(filter, page)
+	if err != nil {
+		h.fail(w, err, "can't encode cursor")
+		return
+	}
+
+	resp := SendSuccess(formatDates(page.Songs, layout))
+	resp.Meta = paginate(w, r, Meta{
+		Total:      page.Total,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+		HasNext:    page.HasNext,
+		NextCursor: next,
+	}, offsetPage(filter.Limit))
+
+	h.response(w, resp, http.StatusOK)
+}
+
+// nextCursor returns the token of the page after songs, or "" when songs is
+// the last page or the order can't be continued by a keyset
+func (h *Handlers) nextCursor(filter models.SongFilter, page models.SongPage) (string, error) {
+	if !page.HasNext || len(page.Songs) == 0 {
+		return "", nil
+	}
+	songs := page.Songs
+	// similarity scores aren't part of the cursor
+	if filter.Match == models.MatchFuzzy && len(filter.Sort) == 0 {
+		return "", nil
+	}
+
+	last := songs[len(songs)-1]
+
+	return h.cursors.Encode(models.SongCursor{
+		Sort:        filter.Sort,
+		ID:          last.ID,
+		Song:        last.Song,
+		GroupName:   last.GroupName,
+		ReleaseDate: last.ReleaseDate,
+	}, filter)
+}
+
+// Search finds songs by a fragment of their lyrics, title or group name
+// @Summary Full-text search over songs
+// @Description Searches song titles, group names and lyrics with postgres full-text search. Results are ordered by rank and carry a lyrics snippet with matches wrapped in <b></b>. Supports web search syntax: quoted phrases, OR and -exclusion.
+// @Tags songs
+// @Produce  json
+// @Param q query string true "Search query"
+// @Param lang query string false "Text search language, both when omitted" Enums(en, ru)
+// @Param limit query int false "Limit" default(10)
+// @Param offset query int false "Offset"
+// @Param date_format query string false "Layout of returned dates" Enums(legacy, iso) default(legacy)
+// @Success 200 {array} models.SongSearchResult "Ranked search results"
+// @Failure 400 {object} Response "Invalid query parameters"
+// @Failure 422 {object} Response "Missing query or unsupported language"
+// @Failure 500 {object} Response "Failed to search songs"
+// @Router /songs/search [get]
+func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
+	layout, err := dateLayout(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, ok := h.limitOffset(w, r)
+	if !ok {
+		return
+	}
+
+	query := models.SearchQuery{
+		Query:    r.URL.Query().Get("q"),
+		Language: r.URL.Query().Get("lang"),
+		Limit:    limit,
+		Offset:   offset,
 	}
 
-	songs, err := h.Service.Get(groupName, songName, releaseDate, limitInt, offsetInt, songID)
+	results, err := h.Service.Search(r.Context(), query)
 	if err != nil {
-		h.response(w, SendError("can't get all songs"), http.StatusInternalServerError)
+		h.fail(w, err, "can't search songs")
 		return
 	}
 
-	h.response(w, SendSuccess(songs), http.StatusOK)
+	for i := range results {
+		results[i].ReleaseDate = results[i].ReleaseDate.In(layout)
+	}
+
+	h.response(w, SendSuccess(results), http.StatusOK)
+}
+
+// GetSongVerses returns a page of the verses of a song
+// @Summary Get paginated verses of a song
+// @Description Returns the verses of a song, verses are stanzas of the lyrics separated by blank lines or section headers like [Chorus]. Each verse carries its number, section type and lines, repeated sections are expanded.
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Number of verses per page" default(5)
+// @Param type query string false "Comma separated section types to keep, e.g. chorus. Types are verse, pre-chorus, chorus, bridge, intro, outro, hook and other"
+// @Success 200 {array} models.Verse "Verses of the page, meta.total is the number of verses"
+// @Header 200 {string} Link "first, prev, next and last pages"
+// @Failure 400 {object} Response "Invalid id or pagination parameters"
+// @Failure 404 {object} Response "Song not found or page out of range"
+// @Failure 500 {object} Response "Failed to get song's verses"
+// @Router /songs/{id}/verses [get]
+func (h *Handlers) GetSongVerses(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	h.verses(w, r, id, func(verses []models.Verse) any { return verses })
 }
 
 // GetVerses returns the paginated song text (verses)
 // @Summary Get paginated song text (verses) from the storage
-// @Description Returns the verses of a song with optional filtering by group name and song name, and pagination
+// @Description Returns the verses of a song as strings, lines of a verse are joined with \n. Prefer GET /songs/{id}/verses.
 // @Tags songs
 // @Produce  json
-// @Param id query int false "Song Id"
+// @Param id query int true "Song Id"
 // @Param page query int false "Page number for pagination" default(1)
 // @Param pageSize query int false "Number of verses per page" default(5)
-// @Param song query string false "Song title"
-// @Param group_name query string false "Group name"
-// @Param releasedate query string false "Song release date in format 02.01.2006"
-// @Success 200 {array} string "Array of song verses"
+// @Param type query string false "Comma separated section types to keep"
+// @Success 200 {array} string "Array of song verses, meta describes the page"
+// @Header 200 {string} Link "first, prev, next and last pages"
 // @Failure 400 {object} Response "Invalid query parameters"
+// @Failure 404 {object} Response "Song not found or page out of range"
 // @Failure 500 {object} Response "Failed to get song's verses"
+// @Deprecated
 // @Router /songs/verses [get]
 func (h *Handlers) GetVerses(w http.ResponseWriter, r *http.Request) {
-	groupName := r.URL.Query().Get("group_name")
-	songName := r.URL.Query().Get("song")
-	releaseDate := r.URL.Query().Get("releasedate")
-	songIDStr := r.URL.Query().Get("id")
-	songID, err := strconv.Atoi(songIDStr)
+	id, err := queryInt(r, "id", 0)
+	if err != nil || id == 0 {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	h.verses(w, r, id, func(verses []models.Verse) any {
+		texts := make([]string, 0, len(verses))
+		for _, v := range verses {
+			texts = append(texts, strings.Join(v.Lines, "\n"))
+		}
+		return texts
+	})
+}
+
+// GetSongSections returns the lyrics of a song split into typed sections
+// @Summary Get the structured lyrics of a song
+// @Description Returns the lyrics split into sections by headers like [Verse 2], [Chorus] or [Bridge], stanzas without a header are verses. A header without lines repeats an earlier section and is marked with repeat, expand fills in its lines.
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Param expand query bool false "Fill in the lines of repeated sections"
+// @Param type query string false "Comma separated section types to keep"
+// @Success 200 {array} models.Section "Sections in song order"
+// @Failure 400 {object} Response "Invalid query parameters"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 500 {object} Response "Failed to get sections"
+// @Router /songs/{id}/sections [get]
+func (h *Handlers) GetSongSections(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	expand := false
+	if val := r.URL.Query().Get("expand"); val != "" {
+		if expand, err = strconv.ParseBool(val); err != nil {
+			h.response(w, SendError(codeBadRequest, "invalid expand parameter"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	types, err := sectionTypes(r)
 	if err != nil {
-		http.Error(w, "invalid songID", http.StatusBadRequest)
+		h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
 		return
 	}
 
-	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	sections, err := h.Service.Sections(r.Context(), id, expand, types)
+	if err != nil {
+		h.fail(w, err, "can't get song sections")
+		return
+	}
+
+	h.response(w, SendSuccess(sections), http.StatusOK)
+}
+
+// sectionTypes parses the comma separated type query parameter
+func sectionTypes(r *http.Request) ([]string, error) {
+	val := r.URL.Query().Get("type")
+	if val == "" {
+		return nil, nil
+	}
+
+	var types []string
+	for _, typ := range strings.Split(val, ",") {
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if !lyrics.IsType(typ) {
+			return nil, fmt.Errorf("invalid section type %q", typ)
+		}
+		types = append(types, typ)
+	}
+
+	return types, nil
+}
+
+// verses writes the page of verses of the song picked by the page and
+// pageSize parameters, render shapes the verses of the response
+func (h *Handlers) verses(w http.ResponseWriter, r *http.Request, id int, render func([]models.Verse) any) {
+	page, err := queryInt(r, "page", 1)
 	if err != nil || page < 1 {
-		page = 1
-		h.log.Warn("Invalid page number, using default value", slog.Int("page", page))
+		h.response(w, SendError(codeBadRequest, "Invalid page parameter"), http.StatusBadRequest)
+		return
 	}
 
-	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	pageSize, err := queryInt(r, "pageSize", 5)
 	if err != nil || pageSize < 1 {
-		pageSize = 5
-		h.log.Warn("Invalid page size, using default value", slog.Int("pageSize", pageSize))
+		h.response(w, SendError(codeBadRequest, "Invalid pageSize parameter"), http.StatusBadRequest)
+		return
 	}
 
-	if page <= 0 || pageSize <= 0 {
-		h.log.Warn("Invalid pagination parameters", "page", page, "pageSize", pageSize)
-		h.response(w, SendError("Invalid pagination parameters"), http.StatusBadRequest)
+	types, err := sectionTypes(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
 		return
 	}
 
 	offset := (page - 1) * pageSize
-	if offset < 0 {
-		offset = 0
-	}
 
-	h.log.Debug("Request parameters", "page", page, "pageSize", pageSize, "offset", offset)
+	h.log.Debug("Request parameters", "song_id", id, "page", page, "pageSize", pageSize, "types", types)
 
-	verses, err := h.Service.GetVerses(groupName, songName, releaseDate, pageSize, offset, songID)
+	verses, total, err := h.Service.Verses(r.Context(), id, types, pageSize, offset)
 	if err != nil {
-		h.log.Error("Error fetching paginated song text", slog.String("error", err.Error()))
-		h.response(w, SendError("Error fetching paginated song text"), http.StatusInternalServerError)
+		h.fail(w, err, "Error fetching paginated song text")
 		return
 	}
 
-	h.log.Debug("song name and groupname", songName, groupName)
+	resp := SendSuccess(render(verses))
+	resp.Meta = paginate(w, r, Meta{
+		Total:   total,
+		Limit:   pageSize,
+		Offset:  offset,
+		HasNext: offset+len(verses) < total,
+	}, numberedPage(pageSize))
+
+	h.response(w, resp, http.StatusOK)
+}
+
+// pathID returns the {id} route variable as a positive integer
+func pathID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, err
+	}
+	if id <= 0 {
+		return 0, fmt.Errorf("id must be positive, got %d", id)
+	}
+
+	return id, nil
+}
+
+// queryInt returns the named query parameter as an integer, or def when it is absent
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(val)
+}
+
+// songFilter reads the song filter query parameters shared by the list endpoints
+func songFilter(r *http.Request) (models.SongFilter, error) {
+	query := r.URL.Query()
+
+	filter := models.SongFilter{
+		GroupName: query.Get("group_name"),
+		Song:      query.Get("song"),
+		Match:     query.Get("match"),
+	}
+
+	switch filter.Match {
+	case "", models.MatchExact, models.MatchPrefix, models.MatchContains, models.MatchFuzzy:
+	default:
+		return models.SongFilter{}, fmt.Errorf("invalid match parameter, expected exact, prefix, contains or fuzzy")
+	}
+
+	var err error
+	if filter.ID, err = queryInt(r, "id", 0); err != nil {
+		return models.SongFilter{}, fmt.Errorf("invalid songID")
+	}
+	if filter.Sort, err = songSort(query.Get("sort")); err != nil {
+		return models.SongFilter{}, err
+	}
+	if filter.Year, err = queryInt(r, "year", 0); err != nil {
+		return models.SongFilter{}, fmt.Errorf("invalid year parameter")
+	}
+
+	dates := []struct {
+		name string
+		dst  *models.Date
+	}{
+		{"releasedate", &filter.ReleaseDate},
+		{"released_from", &filter.ReleasedFrom},
+		{"released_to", &filter.ReleasedTo},
+	}
+	for _, d := range dates {
+		if *d.dst, err = models.ParseDate(query.Get(d.name)); err != nil {
+			return models.SongFilter{}, fmt.Errorf("invalid %s parameter: %v", d.name, err)
+		}
+	}
+
+	return filter, nil
+}
+
+// songSort parses a comma separated list of sortable fields, each optionally
+// prefixed with - for descending order
+func songSort(value string) ([]models.SortField, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var (
+		sort []models.SortField
+		seen = map[string]bool{}
+	)
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		field := models.SortField{Field: strings.TrimPrefix(key, "-"), Desc: strings.HasPrefix(key, "-")}
+
+		switch field.Field {
+		case models.SortByID, models.SortBySong, models.SortByGroupName, models.SortByReleaseDate:
+		default:
+			return nil, fmt.Errorf("invalid sort field %q, expected id, song, group_name or releasedate", key)
+		}
+		if seen[field.Field] {
+			return nil, fmt.Errorf("sort field %q is given twice", field.Field)
+		}
+		seen[field.Field] = true
+
+		sort = append(sort, field)
+	}
+
+	return sort, nil
+}
+
+// dateLayout returns the layout picked with the date_format query parameter
+func dateLayout(r *http.Request) (string, error) {
+	switch r.URL.Query().Get("date_format") {
+	case "", "legacy":
+		return models.LegacyDateLayout, nil
+	case "iso":
+		return models.ISODateLayout, nil
+	}
+
+	return "", fmt.Errorf("invalid date_format parameter, expected legacy or iso")
+}
+
+// formatDates renders the release dates of songs with the given layout
+func formatDates(songs []models.Song, layout string) []models.Song {
+	for i := range songs {
+		songs[i].ReleaseDate = songs[i].ReleaseDate.In(layout)
+	}
 
-	h.response(w, SendSuccess(verses), http.StatusOK)
+	return songs
 }
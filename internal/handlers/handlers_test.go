@@ -2,12 +2,19 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/mock"
+	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Fyefhqdishka/eff-mobile/internal/handlers"
 	"github.com/Fyefhqdishka/eff-mobile/internal/models"
@@ -19,45 +26,109 @@ type MockService struct {
 	mock.Mock
 }
 
-func (m *MockService) Create(song models.Song) (models.Song, error) {
-	args := m.Called(song)
+func (m *MockService) Create(ctx context.Context, song models.Song) (models.Song, error) {
+	args := m.Called(ctx, song)
 	return args.Get(0).(models.Song), args.Error(1)
 }
 
-func (m *MockService) Update(song models.Song) (bool, error) {
-	args := m.Called(song)
+func (m *MockService) Update(ctx context.Context, song models.Song) (bool, error) {
+	args := m.Called(ctx, song)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockService) Delete(song models.Song) (int, error) {
-	args := m.Called(song)
+func (m *MockService) Patch(ctx context.Context, id int, patch models.SongPatch) (models.Song, error) {
+	args := m.Called(ctx, id, patch)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockService) Delete(ctx context.Context, id int) (int, error) {
+	args := m.Called(ctx, id)
 	return args.Int(0), args.Error(1)
 }
 
-func (m *MockService) Get(groupName, songName, releaseDate string, limit, offset, songID int) ([]models.Song, error) {
-	args := m.Called(groupName, songName, releaseDate, limit, offset, songID)
-	return args.Get(0).([]models.Song), args.Error(1)
+func (m *MockService) GetByID(ctx context.Context, id int) (models.Song, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockService) Get(ctx context.Context, filter models.SongFilter) (models.SongPage, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(models.SongPage), args.Error(1)
+}
+
+func (m *MockService) Verses(ctx context.Context, id int, types []string, limit, offset int) ([]models.Verse, int, error) {
+	args := m.Called(ctx, id, types, limit, offset)
+	return args.Get(0).([]models.Verse), args.Int(1), args.Error(2)
+}
+
+func (m *MockService) Sections(ctx context.Context, id int, expand bool, types []string) (models.Sections, error) {
+	args := m.Called(ctx, id, expand, types)
+	return args.Get(0).(models.Sections), args.Error(1)
+}
+
+func (m *MockService) UpstreamStatus() models.UpstreamStatus {
+	return m.Called().Get(0).(models.UpstreamStatus)
+}
+
+func (m *MockService) Enrichment(ctx context.Context, id int) (models.Enrichment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Enrichment), args.Error(1)
+}
+
+func (m *MockService) Refresh(ctx context.Context, id int, apply string, overwriteChordPro bool) (models.SongRefresh, error) {
+	args := m.Called(ctx, id, apply, overwriteChordPro)
+	return args.Get(0).(models.SongRefresh), args.Error(1)
+}
+
+func (m *MockService) RefreshGroup(ctx context.Context, groupID int, apply string, overwriteChordPro bool, limit, offset int) (models.RefreshPage, error) {
+	args := m.Called(ctx, groupID, apply, overwriteChordPro, limit, offset)
+	return args.Get(0).(models.RefreshPage), args.Error(1)
+}
+
+func (m *MockService) Chords(ctx context.Context, id int, transpose int, notation string) (models.ChordSheet, error) {
+	args := m.Called(ctx, id, transpose, notation)
+	return args.Get(0).(models.ChordSheet), args.Error(1)
+}
+
+func (m *MockService) Search(ctx context.Context, query models.SearchQuery) ([]models.SongSearchResult, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]models.SongSearchResult), args.Error(1)
 }
 
-func (m *MockService) GetVerses(groupName, songName, releaseDate string, limit, offset, songID int) ([]string, error) {
-	args := m.Called(groupName, songName, releaseDate, limit, offset, songID)
-	return args.Get(0).([]string), args.Error(1)
+func (m *MockService) ImportLRC(ctx context.Context, id int, r io.Reader) (models.TimedLyrics, error) {
+	data, _ := io.ReadAll(r)
+	args := m.Called(ctx, id, string(data))
+	return args.Get(0).(models.TimedLyrics), args.Error(1)
+}
+
+func (m *MockService) TimedLyrics(ctx context.Context, id int) (models.TimedLyrics, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.TimedLyrics), args.Error(1)
+}
+
+func (m *MockService) LyricsAt(ctx context.Context, id int, t time.Duration) (models.LyricsPosition, error) {
+	args := m.Called(ctx, id, t)
+	return args.Get(0).(models.LyricsPosition), args.Error(1)
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
 }
 
 func TestCreateSong(t *testing.T) {
 	mockService := new(MockService)
 
 	// Мок-сервис возвращает песню с заполненным полем Song
-	mockService.On("Create", mock.Anything).Return(models.Song{
+	mockService.On("Create", mock.Anything, mock.Anything).Return(models.Song{
 		GroupName:   "GroupName",
 		Song:        "SongName",
 		Text:        "Verse 1\nVerse 2",
 		ID:          2,
 		Link:        "sting",
-		ReleaseDate: "string",
+		ReleaseDate: models.NewDate(2006, time.January, 2),
 	}, nil)
 
-	h := handlers.NewHandlers(nil, mockService)
+	h := handlers.NewHandlers(nil, mockService, nil, nil)
 
 	newSong := models.Song{
 		GroupName:   "GroupName",
@@ -65,7 +136,7 @@ func TestCreateSong(t *testing.T) {
 		Text:        "Verse 1\nVerse 2",
 		ID:          2,
 		Link:        "sting",
-		ReleaseDate: "string",
+		ReleaseDate: models.NewDate(2006, time.January, 2),
 	}
 
 	songJSON, err := json.Marshal(newSong)
@@ -99,14 +170,20 @@ func TestGet(t *testing.T) {
 	mockService := new(MockService)
 	mockLog := slog.Logger{}
 
-	handler := handlers.NewHandlers(&mockLog, mockService)
+	handler := handlers.NewHandlers(&mockLog, mockService, nil, nil)
 
 	songs := []models.Song{
-		{ID: 1, GroupName: "Group1", Song: "Song1", Text: "Verse1", Link: "Link1", ReleaseDate: "2024-01-01"},
-		{ID: 2, GroupName: "Group2", Song: "Song2", Text: "Verse2", Link: "Link2", ReleaseDate: "2024-02-01"},
+		{ID: 1, GroupName: "Group1", Song: "Song1", Text: "Verse1", Link: "Link1", ReleaseDate: models.NewDate(2024, time.January, 1)},
+		{ID: 2, GroupName: "Group2", Song: "Song2", Text: "Verse2", Link: "Link2", ReleaseDate: models.NewDate(2024, time.February, 1)},
 	}
 
-	mockService.On("Get", "Group1", "Song1", "2024-01-01", 10, 0, 0).Return(songs, nil)
+	filter := models.SongFilter{
+		GroupName:   "Group1",
+		Song:        "Song1",
+		ReleaseDate: models.NewDate(2024, time.January, 1),
+		Limit:       10,
+	}
+	mockService.On("Get", mock.Anything, filter).Return(models.SongPage{Songs: songs, Total: len(songs)}, nil)
 
 	req, err := http.NewRequest("GET", "/songs?group_name=Group1&song=Song1&releasedate=2024-01-01&id=0", nil)
 	if err != nil {
@@ -138,3 +215,748 @@ func TestGet(t *testing.T) {
 		t.Fatal("No songs found in response")
 	}
 }
+
+func TestTimeoutPropagatesDeadline(t *testing.T) {
+	mockService := new(MockService)
+	mockLog := slog.Logger{}
+
+	handler := handlers.NewHandlers(&mockLog, mockService, nil, nil)
+
+	hasDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	})
+	mockService.On("Get", hasDeadline, models.SongFilter{Limit: 10}).Return(models.SongPage{Songs: []models.Song{}}, nil)
+
+	req := httptest.NewRequest("GET", "/songs", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.Timeout(time.Second)(http.HandlerFunc(handler.Get)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestErrorMapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"not found", fmt.Errorf("song with ID 5 %w", models.ErrNotFound), http.StatusNotFound, "not_found"},
+		{"conflict", models.ErrConflict, http.StatusConflict, "conflict"},
+		{"validation", fmt.Errorf("%w: song is required", models.ErrValidation), http.StatusUnprocessableEntity, "validation_failed"},
+		{"upstream", fmt.Errorf("%w: boom", models.ErrUpstream), http.StatusBadGateway, "upstream_failed"},
+		{"timeout", fmt.Errorf("%w: %w", models.ErrUpstream, context.DeadlineExceeded), http.StatusGatewayTimeout, "timeout"},
+		{"unexpected", errors.New("boom"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockService)
+			mockService.On("Update", mock.Anything, mock.Anything).Return(false, tt.err)
+
+			h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+			req := httptest.NewRequest("PUT", "/songs/5", bytes.NewBufferString(`{"id":5,"song":"s","group_name":"g"}`))
+			req = mux.SetURLVars(req, map[string]string{"id": "5"})
+			rr := httptest.NewRecorder()
+
+			h.Update(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+
+			var response handlers.Response
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
+
+func TestDeleteUsesPathID(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("Delete", mock.Anything, 5).Return(5, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("DELETE", "/songs/5", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	h.Delete(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateInvalidPathID(t *testing.T) {
+	mockService := new(MockService)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("PUT", "/songs/abc", bytes.NewBufferString(`{"song":"s","group_name":"g"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "abc"})
+	rr := httptest.NewRecorder()
+
+	h.Update(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockService.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestUpdateBodyIDConflict(t *testing.T) {
+	mockService := new(MockService)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("PUT", "/songs/5", bytes.NewBufferString(`{"id":7,"song":"s","group_name":"g"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	h.Update(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	mockService.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestGetByIDNotFound(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("GetByID", mock.Anything, 42).Return(models.Song{}, fmt.Errorf("song with ID 42 %w", models.ErrNotFound))
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs/42", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "42"})
+	rr := httptest.NewRecorder()
+
+	h.GetByID(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchMergePatch(t *testing.T) {
+	mockService := new(MockService)
+
+	link := "https://example.com"
+	empty := ""
+	expected := models.SongPatch{Link: &link, Text: &empty}
+	mockService.On("Patch", mock.Anything, 5, expected).Return(models.Song{ID: 5, Link: link}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("PATCH", "/songs/5", bytes.NewBufferString(`{"link":"https://example.com","text":null}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	h.Patch(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchJSONPatch(t *testing.T) {
+	mockService := new(MockService)
+
+	name := "New name"
+	expected := models.SongPatch{Song: &name}
+	mockService.On("Patch", mock.Anything, 5, expected).Return(models.Song{ID: 5, Song: name}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("PATCH", "/songs/5", bytes.NewBufferString(`[{"op":"replace","path":"/song","value":"New name"}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	h.Patch(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchRejectsInvalidDocuments(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"unknown field", "application/merge-patch+json", `{"rating":5}`, http.StatusUnprocessableEntity},
+		{"remove required", "application/merge-patch+json", `{"song":null}`, http.StatusUnprocessableEntity},
+		{"id mismatch", "application/merge-patch+json", `{"id":6}`, http.StatusConflict},
+		{"unsupported op", "application/json-patch+json", `[{"op":"move","from":"/song","path":"/text"}]`, http.StatusUnprocessableEntity},
+		{"unsupported media type", "text/plain", `link=x`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockService)
+
+			h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+			req := httptest.NewRequest("PATCH", "/songs/5", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = mux.SetURLVars(req, map[string]string{"id": "5"})
+			rr := httptest.NewRecorder()
+
+			h.Patch(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			mockService.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+type MockGroupService struct {
+	mock.Mock
+}
+
+func (m *MockGroupService) Create(ctx context.Context, name string) (models.Group, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupService) Rename(ctx context.Context, id int, rename models.GroupRename) (models.Group, error) {
+	args := m.Called(ctx, id, rename)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupService) Delete(ctx context.Context, id int) (int, error) {
+	args := m.Called(ctx, id)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockGroupService) GetByID(ctx context.Context, id int) (models.Group, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupService) Get(ctx context.Context, limit, offset int) ([]models.Group, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).([]models.Group), args.Error(1)
+}
+
+func (m *MockGroupService) Songs(ctx context.Context, id, limit, offset int) (models.SongPage, error) {
+	args := m.Called(ctx, id, limit, offset)
+	return args.Get(0).(models.SongPage), args.Error(1)
+}
+
+func TestRenameGroupConflict(t *testing.T) {
+	mockGroups := new(MockGroupService)
+	mockGroups.On("Rename", mock.Anything, 3, models.GroupRename{Name: "Muse"}).Return(models.Group{}, fmt.Errorf("group %q already exists: %w", "Muse", models.ErrConflict))
+
+	h := handlers.NewHandlers(testLogger(), nil, mockGroups, nil)
+
+	req := httptest.NewRequest("PUT", "/groups/3", bytes.NewBufferString(`{"name":"Muse"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	h.RenameGroup(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	mockGroups.AssertExpectations(t)
+}
+
+func TestGetGroupSongs(t *testing.T) {
+	mockGroups := new(MockGroupService)
+	mockGroups.On("Songs", mock.Anything, 3, 5, 10).Return(models.SongPage{Songs: []models.Song{{ID: 1, GroupID: 3, Song: "Song1"}}, Total: 11}, nil)
+
+	h := handlers.NewHandlers(testLogger(), nil, mockGroups, nil)
+
+	req := httptest.NewRequest("GET", "/groups/3/songs?limit=5&offset=10", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	h.GetGroupSongs(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"meta":{"total":11,"limit":5,"offset":10,"page":3,"has_next":false}`)
+	assert.Equal(t, `</groups/3/songs?limit=5&offset=0>; rel="first", </groups/3/songs?limit=5&offset=5>; rel="prev", </groups/3/songs?limit=5&offset=10>; rel="last"`, rr.Header().Get("Link"))
+	mockGroups.AssertExpectations(t)
+}
+
+func TestSetSongGroup(t *testing.T) {
+	mockService := new(MockService)
+
+	name := "Muse"
+	mockService.On("Patch", mock.Anything, 5, models.SongPatch{GroupName: &name}).Return(models.Song{ID: 5, GroupName: name}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("PUT", "/songs/5/group", bytes.NewBufferString(`{"name":"Muse"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	h.SetSongGroup(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDateFilters(t *testing.T) {
+	mockService := new(MockService)
+
+	filter := models.SongFilter{
+		ReleasedFrom: models.NewDate(2020, time.March, 1),
+		ReleasedTo:   models.NewDate(2020, time.December, 31),
+		Year:         2020,
+		Limit:        10,
+	}
+	songs := []models.Song{{ID: 1, Song: "Song1", ReleaseDate: models.NewDate(2020, time.May, 4)}}
+	mockService.On("Get", mock.Anything, filter).Return(models.SongPage{Songs: songs, Total: len(songs)}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs?released_from=01.03.2020&released_to=2020-12-31&year=2020&date_format=iso", nil)
+	rr := httptest.NewRecorder()
+
+	h.Get(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"releasedate":"2020-05-04"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetInvalidDate(t *testing.T) {
+	mockService := new(MockService)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs?released_from=yesterday", nil)
+	rr := httptest.NewRecorder()
+
+	h.Get(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockService.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestSearch(t *testing.T) {
+	mockService := new(MockService)
+
+	query := models.SearchQuery{Query: "yesterday all my troubles", Language: models.SearchLangEnglish, Limit: 5}
+	results := []models.SongSearchResult{{
+		Song:    models.Song{ID: 3, Song: "Yesterday", GroupName: "The Beatles"},
+		Rank:    0.6,
+		Snippet: "<b>Yesterday</b>, <b>all</b> <b>my</b> <b>troubles</b> seemed so far away",
+	}}
+	mockService.On("Search", mock.Anything, query).Return(results, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs/search?q=yesterday+all+my+troubles&lang=en&limit=5", nil)
+	rr := httptest.NewRecorder()
+
+	h.Search(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"rank":0.6`)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetFuzzyMatch(t *testing.T) {
+	mockService := new(MockService)
+
+	score := 0.57
+	filter := models.SongFilter{GroupName: "beatls", Match: models.MatchFuzzy, Limit: 10}
+	songs := []models.Song{{ID: 1, GroupName: "The Beatles", Song: "Yesterday", Score: &score}}
+	mockService.On("Get", mock.Anything, filter).Return(models.SongPage{Songs: songs, Total: len(songs)}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs?group_name=beatls&match=fuzzy", nil)
+	rr := httptest.NewRecorder()
+
+	h.Get(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"score":0.57`)
+	mockService.AssertExpectations(t)
+
+	req = httptest.NewRequest("GET", "/songs?song=yesterday&match=soundex", nil)
+	rr = httptest.NewRecorder()
+
+	h.Get(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetSort(t *testing.T) {
+	mockService := new(MockService)
+
+	filter := models.SongFilter{
+		Sort: []models.SortField{
+			{Field: models.SortByReleaseDate, Desc: true},
+			{Field: models.SortByGroupName},
+			{Field: models.SortBySong},
+		},
+		Limit: 10,
+	}
+	mockService.On("Get", mock.Anything, filter).Return(models.SongPage{Songs: []models.Song{}}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs?sort=-releasedate,group_name,song", nil)
+	rr := httptest.NewRecorder()
+
+	h.Get(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+
+	for _, sort := range []string{"text", "song,-song", "-"} {
+		req = httptest.NewRequest("GET", "/songs?sort="+sort, nil)
+		rr = httptest.NewRecorder()
+
+		h.Get(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, sort)
+	}
+}
+
+func TestGetCursorPagination(t *testing.T) {
+	mockService := new(MockService)
+
+	sort := []models.SortField{{Field: models.SortByReleaseDate, Desc: true}}
+	page := []models.Song{
+		{ID: 7, Song: "Song7", ReleaseDate: models.NewDate(2021, time.May, 4)},
+		{ID: 3, Song: "Song3", ReleaseDate: models.NewDate(2020, time.May, 4)},
+	}
+	mockService.On("Get", mock.Anything, models.SongFilter{Sort: sort, Limit: 2}).Return(models.SongPage{Songs: page, Total: 3, HasNext: true}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, []byte("secret"))
+
+	req := httptest.NewRequest("GET", "/songs?sort=-releasedate&limit=2", nil)
+	rr := httptest.NewRecorder()
+
+	h.Get(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var resp struct {
+		Meta handlers.Meta `json:"meta"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Meta.NextCursor)
+
+	mockService.On("Get", mock.Anything, mock.MatchedBy(func(f models.SongFilter) bool {
+		return f.After != nil && f.After.ID == 3 && f.After.ReleaseDate.Equal(models.NewDate(2020, time.May, 4)) && len(f.Sort) == 1
+	})).Return(models.SongPage{Songs: page[1:], Total: 3}, nil)
+
+	req = httptest.NewRequest("GET", "/songs?limit=2&cursor="+resp.Meta.NextCursor, nil)
+	rr = httptest.NewRecorder()
+
+	h.Get(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "next_cursor")
+	mockService.AssertExpectations(t)
+
+	forged := handlers.NewHandlers(testLogger(), mockService, nil, []byte("another secret"))
+
+	req = httptest.NewRequest("GET", "/songs?limit=2&cursor="+resp.Meta.NextCursor, nil)
+	rr = httptest.NewRecorder()
+
+	forged.Get(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// the cursor can't be replayed against another listing
+	for _, query := range []string{"sort=song", "song=Song3", "year=2020", "match=prefix"} {
+		req = httptest.NewRequest("GET", "/songs?"+query+"&limit=2&cursor="+resp.Meta.NextCursor, nil)
+		rr = httptest.NewRecorder()
+
+		h.Get(rr, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code, query)
+	}
+}
+
+func TestGetSongVerses(t *testing.T) {
+	mockService := new(MockService)
+
+	verses := []models.Verse{{Number: 3, Type: models.SectionVerse, Lines: []string{"third verse"}}, {Number: 4, Type: models.SectionVerse, Lines: []string{"fourth", "verse"}}}
+	mockService.On("Verses", mock.Anything, 1, []string(nil), 2, 2).Return(verses, 5, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs/1/verses?page=2&pageSize=2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	h.GetSongVerses(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"result":[{"number":3,"type":"verse","lines":["third verse"]},{"number":4,"type":"verse","lines":["fourth","verse"]}]`)
+	assert.Contains(t, rr.Body.String(), `"meta":{"total":5,"limit":2,"offset":2,"page":2,"has_next":true}`)
+	assert.Contains(t, rr.Header().Get("Link"), `</songs/1/verses?page=3&pageSize=2>; rel="next"`)
+	assert.Contains(t, rr.Header().Get("Link"), `</songs/1/verses?page=3&pageSize=2>; rel="last"`)
+
+	req = httptest.NewRequest("GET", "/songs/verses?id=1&page=2&pageSize=2", nil)
+	rr = httptest.NewRecorder()
+
+	h.GetVerses(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"result":["third verse","fourth\nverse"]`)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetSongSections(t *testing.T) {
+	mockService := new(MockService)
+
+	sections := models.Sections{{Type: models.SectionChorus, Label: "Chorus", Lines: []string{"la la la"}}}
+	mockService.On("Sections", mock.Anything, 1, true, []string{models.SectionChorus}).Return(sections, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs/1/sections?expand=true&type=Chorus", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	h.GetSongSections(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"result":[{"type":"chorus","label":"Chorus","lines":["la la la"]}]`)
+	mockService.AssertExpectations(t)
+
+	req = httptest.NewRequest("GET", "/songs/1/verses?type=solo", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr = httptest.NewRecorder()
+
+	h.GetSongVerses(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestUploadLyrics(t *testing.T) {
+	mockService := new(MockService)
+
+	file := "[ti:Yesterday]\n[00:01.00]Yesterday\n"
+	lyrics := models.TimedLyrics{Meta: map[string]string{"ti": "Yesterday"}, Lines: []models.TimedLine{{Time: 1000, Text: "Yesterday"}}}
+	mockService.On("ImportLRC", mock.Anything, 1, file).Return(lyrics, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "yesterday.lrc")
+	part.Write([]byte(file))
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/songs/1/lyrics", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	h.UploadLyrics(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"lines":[{"time_ms":1000,"text":"Yesterday"}]`)
+
+	req = httptest.NewRequest("POST", "/songs/1/lyrics", bytes.NewReader(bytes.Repeat([]byte("a"), 2<<20)))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr = httptest.NewRecorder()
+
+	h.UploadLyrics(rr, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestExportLyrics(t *testing.T) {
+	mockService := new(MockService)
+
+	lyrics := models.TimedLyrics{Offset: -250, Lines: []models.TimedLine{{Time: 1000, Text: "Yesterday"}}}
+	mockService.On("TimedLyrics", mock.Anything, 1).Return(lyrics, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("GET", "/songs/1/lyrics.lrc", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	h.ExportLyrics(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[offset:-250]\n[00:01.00]Yesterday\n", rr.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestGetLyricsAt(t *testing.T) {
+	mockService := new(MockService)
+
+	line := models.TimedLine{Time: 1000, Text: "Yesterday"}
+	mockService.On("LyricsAt", mock.Anything, 1, 72500*time.Millisecond).Return(models.LyricsPosition{Index: 0, Line: &line}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	for _, pos := range []string{"72.5", "1m12.5s"} {
+		req := httptest.NewRequest("GET", "/songs/1/lyrics/at?t="+pos, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+
+		h.GetLyricsAt(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code, pos)
+		assert.Contains(t, rr.Body.String(), `"line":{"time_ms":1000,"text":"Yesterday"}`, pos)
+	}
+
+	req := httptest.NewRequest("GET", "/songs/1/lyrics/at?t=soon", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	h.GetLyricsAt(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	for _, pos := range []string{"NaN", "Inf", "-Inf", "-1", "-1s", "1e300"} {
+		req := httptest.NewRequest("GET", "/songs/1/lyrics/at?t="+pos, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+
+		h.GetLyricsAt(rr, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code, pos)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestGetSongChords(t *testing.T) {
+	mockService := new(MockService)
+
+	sheet := models.ChordSheet{Key: "D", Sections: []models.ChordSection{{
+		Type:  models.SectionVerse,
+		Lines: []models.ChordLine{{Text: "Let it be", Chords: []models.ChordPosition{{Offset: 7, Chord: "Bm"}}}},
+	}}}
+	mockService.On("Chords", mock.Anything, 1, 2, models.NotationLatin).Return(sheet, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	// an unencoded + arrives as a space
+	req := httptest.NewRequest("GET", "/songs/1/chords?transpose=+2&format=text", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	h.GetSongChords(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "Key: D\n\n       Bm\nLet it be\n", rr.Body.String())
+
+	req = httptest.NewRequest("GET", "/songs/1/chords?transpose=%2B2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr = httptest.NewRecorder()
+
+	h.GetSongChords(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"chords":[{"offset":7,"chord":"Bm"}]`)
+
+	req = httptest.NewRequest("GET", "/songs/1/chords?format=pdf", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr = httptest.NewRecorder()
+
+	h.GetSongChords(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetUpstreamStatus(t *testing.T) {
+	mockService := new(MockService)
+
+	retryAt := time.Date(2026, time.October, 18, 12, 0, 30, 0, time.UTC)
+	mockService.On("UpstreamStatus").Return(models.UpstreamStatus{State: "open", Failures: 5, RetryAt: &retryAt})
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	rr := httptest.NewRecorder()
+	h.GetUpstreamStatus(rr, httptest.NewRequest("GET", "/health/upstream", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"result":{"state":"open","failures":5,"retry_at":"2026-10-18T12:00:30Z"}`)
+}
+
+func TestGetSongEnrichment(t *testing.T) {
+	mockService := new(MockService)
+
+	next := time.Date(2026, time.October, 18, 12, 0, 30, 0, time.UTC)
+	mockService.On("Enrichment", mock.Anything, 1).Return(models.Enrichment{
+		Status:        models.EnrichmentPending,
+		Attempts:      2,
+		LastError:     "connection refused",
+		NextAttemptAt: &next,
+	}, nil)
+	mockService.On("Enrichment", mock.Anything, 2).Return(models.Enrichment{}, fmt.Errorf("song with ID 2 %w", models.ErrNotFound))
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+	r := mux.NewRouter()
+	r.HandleFunc("/songs/{id}/enrichment", h.GetSongEnrichment).Methods("GET")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/songs/1/enrichment", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"result":{"status":"pending","attempts":2,"last_error":"connection refused","next_attempt_at":"2026-10-18T12:00:30Z"}`)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/songs/2/enrichment", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestRefreshSong(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("Refresh", mock.Anything, 1, "missing", false).Return(models.SongRefresh{
+		ID:        1,
+		Song:      "Song",
+		GroupName: "Group",
+		Apply:     models.RefreshApplyMissing,
+		Diff:      []models.FieldDiff{{Field: models.FieldLink, Stored: "", Upstream: "link", Applied: true}},
+	}, nil)
+	mockService.On("Refresh", mock.Anything, 2, "", true).Return(models.SongRefresh{}, fmt.Errorf("%w: connection refused", models.ErrUpstream))
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+	r := mux.NewRouter()
+	r.HandleFunc("/songs/{id}/refresh", h.RefreshSong).Methods("POST")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/songs/1/refresh?apply=missing", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"diff":[{"field":"link","stored":"","upstream":"link","applied":true}]`)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/songs/2/refresh?overwrite_chordpro=true", nil))
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/songs/2/refresh?overwrite_chordpro=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRefreshGroupSongs(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("RefreshGroup", mock.Anything, 3, "none", false, 5, 0).Return(models.RefreshPage{
+		Refreshes: []models.SongRefresh{{ID: 1, Song: "Song1", GroupName: "Group", Apply: models.RefreshApplyNone, Diff: []models.FieldDiff{}, Error: "upstream enrichment failed: timeout"}},
+		Total:     6,
+		HasNext:   true,
+	}, nil)
+
+	h := handlers.NewHandlers(testLogger(), mockService, nil, nil)
+
+	req := httptest.NewRequest("POST", "/groups/3/refresh?apply=none&limit=5", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	h.RefreshGroupSongs(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"error":"upstream enrichment failed: timeout"`)
+	assert.Contains(t, rr.Body.String(), `"meta":{"total":6,"limit":5,"offset":0,"page":1,"has_next":true}`)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// GetUpstreamStatus reports the circuit breaker of the enrichment API client
+// @Summary Enrichment API status
+// @Description Returns the state of the circuit breaker guarding the music info API. While it is open, enrichment of new songs is postponed until retry_at. With several providers configured, state is the best of their states and providers lists each of them. With the details cache turned on, cache holds its counters since the start.
+// @Tags health
+// @Produce  json
+// @Success 200 {object} models.UpstreamStatus "Circuit breaker state and cache counters"
+// @Router /health/upstream [get]
+func (h *Handlers) GetUpstreamStatus(w http.ResponseWriter, r *http.Request) {
+	h.response(w, SendSuccess(h.Service.UpstreamStatus()), http.StatusOK)
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/lrc"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxLRCSize bounds uploaded LRC files
+const maxLRCSize = 1 << 20
+
+// UploadLyrics imports timed lyrics from an LRC file
+// @Summary Upload timed lyrics of a song
+// @Description Imports an .lrc file sent as the request body or as the file field of a multipart form, replacing the stored timed lyrics. ID tags ([ar:], [ti:], ...) are kept as metadata, [offset:] shifts every line, lines with several timestamps are repeated at each of them.
+// @Tags lyrics
+// @Accept  plain
+// @Accept  mpfd
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Param file formData file false "LRC file when sent as a multipart form"
+// @Success 200 {object} models.TimedLyrics "Imported lyrics"
+// @Failure 400 {object} Response "Invalid id or form"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 413 {object} Response "File larger than 1 MiB"
+// @Failure 422 {object} Response "Malformed LRC"
+// @Failure 500 {object} Response "Failed to store lyrics"
+// @Router /songs/{id}/lyrics [post]
+func (h *Handlers) UploadLyrics(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxLRCSize)
+
+	var src io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			h.lyricsBodyError(w, err, "can't read file field of the form")
+			return
+		}
+		defer file.Close()
+		src = file
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		h.lyricsBodyError(w, err, "can't read request body")
+		return
+	}
+
+	lyrics, err := h.Service.ImportLRC(r.Context(), id, bytes.NewReader(data))
+	if err != nil {
+		h.fail(w, err, "can't import lyrics")
+		return
+	}
+
+	h.response(w, SendSuccess(lyrics), http.StatusOK)
+}
+
+// lyricsBodyError reports an unreadable upload, telling oversized ones apart
+func (h *Handlers) lyricsBodyError(w http.ResponseWriter, err error, msg string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.response(w, SendError(codePayloadTooLarge, fmt.Sprintf("file is larger than %d bytes", tooLarge.Limit)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	h.response(w, SendError(codeBadRequest, msg), http.StatusBadRequest)
+}
+
+// GetLyrics returns the timed lyrics of a song
+// @Summary Get timed lyrics of a song
+// @Tags lyrics
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Success 200 {object} models.TimedLyrics "Timed lyrics"
+// @Failure 400 {object} Response "Invalid id"
+// @Failure 404 {object} Response "Song or its timed lyrics not found"
+// @Failure 500 {object} Response "Failed to get lyrics"
+// @Router /songs/{id}/lyrics [get]
+func (h *Handlers) GetLyrics(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	lyrics, err := h.Service.TimedLyrics(r.Context(), id)
+	if err != nil {
+		h.fail(w, err, "can't get lyrics")
+		return
+	}
+
+	h.response(w, SendSuccess(lyrics), http.StatusOK)
+}
+
+// ExportLyrics returns the timed lyrics of a song as an LRC file
+// @Summary Download timed lyrics as LRC
+// @Tags lyrics
+// @Produce  plain
+// @Param id path int true "Song Id"
+// @Success 200 {string} string "LRC file"
+// @Failure 400 {object} Response "Invalid id"
+// @Failure 404 {object} Response "Song or its timed lyrics not found"
+// @Failure 500 {object} Response "Failed to get lyrics"
+// @Router /songs/{id}/lyrics.lrc [get]
+func (h *Handlers) ExportLyrics(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	lyrics, err := h.Service.TimedLyrics(r.Context(), id)
+	if err != nil {
+		h.fail(w, err, "can't get lyrics")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%d.lrc"`, id))
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, lrc.Format(lyrics))
+}
+
+// GetLyricsAt returns the line active at a playback position
+// @Summary Get the active line of timed lyrics
+// @Description Returns the line shown at the playback position t with its index and the line that follows. Before the first line index is -1 and line is null.
+// @Tags lyrics
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Param t query string true "Playback position in seconds (12.5) or as a duration (1m12.5s)"
+// @Success 200 {object} models.LyricsPosition "Active line"
+// @Failure 400 {object} Response "Invalid id or position"
+// @Failure 404 {object} Response "Song or its timed lyrics not found"
+// @Failure 422 {object} Response "Negative, infinite or NaN position"
+// @Failure 500 {object} Response "Failed to get lyrics"
+// @Router /songs/{id}/lyrics/at [get]
+func (h *Handlers) GetLyricsAt(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	t, err := playbackPosition(r.URL.Query().Get("t"))
+	if errors.Is(err, models.ErrValidation) {
+		h.fail(w, err, "invalid position")
+		return
+	}
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	pos, err := h.Service.LyricsAt(r.Context(), id, t)
+	if err != nil {
+		h.fail(w, err, "can't get lyrics")
+		return
+	}
+
+	h.response(w, SendSuccess(pos), http.StatusOK)
+}
+
+// playbackPosition parses seconds like 12.5 or a duration like 1m12.5s.
+// Positions that parse but aren't a point of the song are ErrValidation.
+func playbackPosition(val string) (time.Duration, error) {
+	if val == "" {
+		return 0, fmt.Errorf("t parameter is required")
+	}
+
+	if sec, err := strconv.ParseFloat(val, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+		switch {
+		case math.IsNaN(sec) || math.IsInf(sec, 0):
+			return 0, fmt.Errorf("%w: position must be a finite number of seconds", models.ErrValidation)
+		case sec < 0:
+			return 0, fmt.Errorf("%w: position can't be negative", models.ErrValidation)
+		case sec > math.MaxInt64/float64(time.Second):
+			return 0, fmt.Errorf("%w: position is too large", models.ErrValidation)
+		}
+		return time.Duration(sec * float64(time.Second)), nil
+	}
+
+	t, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid t parameter, expected seconds or a duration like 1m12.5s")
+	}
+	if t < 0 {
+		return 0, fmt.Errorf("%w: position can't be negative", models.ErrValidation)
+	}
+
+	return t, nil
+}
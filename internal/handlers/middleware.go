@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout bounds every request context by the given duration, so the
+// service, storage and client layers stop working once the deadline passes
+// or the client goes away.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
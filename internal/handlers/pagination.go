@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// pageAt moves the pagination parameters of q to the page starting at offset
+type pageAt func(q url.Values, offset int)
+
+// offsetPage positions pages with the limit and offset parameters
+func offsetPage(limit int) pageAt {
+	return func(q url.Values, offset int) {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+	}
+}
+
+// numberedPage positions pages with the page and pageSize parameters
+func numberedPage(pageSize int) pageAt {
+	return func(q url.Values, offset int) {
+		q.Set("page", strconv.Itoa(offset/pageSize+1))
+		q.Set("pageSize", strconv.Itoa(pageSize))
+	}
+}
+
+// paginate completes meta and links the neighbouring pages of the request in
+// an RFC 8288 Link header. A request continuing a cursor only gets first and
+// next links, its position in the listing is unknown.
+func paginate(w http.ResponseWriter, r *http.Request, meta Meta, at pageAt) *Meta {
+	links := map[string]url.Values{}
+	link := func(rel string, offset int) {
+		q := r.URL.Query()
+		q.Del("cursor")
+		at(q, offset)
+		links[rel] = q
+	}
+
+	link("first", 0)
+
+	if r.URL.Query().Has("cursor") {
+		if meta.NextCursor != "" {
+			q := r.URL.Query()
+			q.Set("cursor", meta.NextCursor)
+			links["next"] = q
+		}
+	} else {
+		meta.Page = meta.Offset/meta.Limit + 1
+
+		if meta.Offset > 0 {
+			link("prev", max(meta.Offset-meta.Limit, 0))
+		}
+		if meta.HasNext {
+			link("next", meta.Offset+meta.Limit)
+		}
+		link("last", max(meta.Total-1, 0)/meta.Limit*meta.Limit)
+	}
+
+	var values []string
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if q, ok := links[rel]; ok {
+			u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+			values = append(values, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel))
+		}
+	}
+	w.Header().Set("Link", strings.Join(values, ", "))
+
+	return &meta
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+)
+
+var errUnsupportedMediaType = errors.New("unsupported patch media type")
+
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// jsonPatchOp is a single RFC 6902 operation
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// patchField returns the SongPatch field addressed by a JSON member name and
+// whether the field may be cleared
+func patchField(patch *models.SongPatch, name string) (**string, bool, bool) {
+	switch name {
+	case "group_name":
+		return &patch.GroupName, false, true
+	case "song":
+		return &patch.Song, false, true
+	case "text":
+		return &patch.Text, true, true
+	case "text_format":
+		return &patch.TextFormat, true, true
+	case "link":
+		return &patch.Link, true, true
+	}
+
+	return nil, false, false
+}
+
+// setPatchField applies value to the named field, a nil value clears it
+func setPatchField(patch *models.SongPatch, name string, value json.RawMessage) error {
+	if name == "releasedate" {
+		var date models.Date
+		if value != nil {
+			if err := json.Unmarshal(value, &date); err != nil {
+				return fmt.Errorf("%w: field %q: %v", models.ErrValidation, name, err)
+			}
+		}
+		patch.ReleaseDate = &date
+		return nil
+	}
+
+	field, clearable, ok := patchField(patch, name)
+	if !ok {
+		return fmt.Errorf("%w: unknown field %q", models.ErrValidation, name)
+	}
+
+	if value == nil || string(value) == "null" {
+		if !clearable {
+			return fmt.Errorf("%w: field %q can't be removed", models.ErrValidation, name)
+		}
+		empty := ""
+		*field = &empty
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(value, &str); err != nil {
+		return fmt.Errorf("%w: field %q must be a string", models.ErrValidation, name)
+	}
+	*field = &str
+
+	return nil
+}
+
+// checkPatchID makes sure an "id" member, if present, matches the path id
+func checkPatchID(value json.RawMessage, id int) error {
+	var bodyID int
+	if err := json.Unmarshal(value, &bodyID); err != nil {
+		return fmt.Errorf("%w: field \"id\" must be an integer", models.ErrValidation)
+	}
+	if bodyID != id {
+		return fmt.Errorf("%w: body id %d does not match path id %d", models.ErrConflict, bodyID, id)
+	}
+
+	return nil
+}
+
+// parseMergePatch decodes an RFC 7396 merge patch document
+func parseMergePatch(body []byte, id int) (models.SongPatch, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return models.SongPatch{}, fmt.Errorf("%w: merge patch must be a JSON object", models.ErrValidation)
+	}
+
+	var patch models.SongPatch
+	for name, value := range doc {
+		if name == "id" {
+			if err := checkPatchID(value, id); err != nil {
+				return models.SongPatch{}, err
+			}
+			continue
+		}
+		if err := setPatchField(&patch, name, value); err != nil {
+			return models.SongPatch{}, err
+		}
+	}
+
+	return patch, nil
+}
+
+// parseJSONPatch decodes an RFC 6902 document. Only add, replace and remove
+// on top-level song fields are supported.
+func parseJSONPatch(body []byte, id int) (models.SongPatch, error) {
+	var ops []jsonPatchOp
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return models.SongPatch{}, fmt.Errorf("%w: json patch must be an array of operations", models.ErrValidation)
+	}
+
+	var patch models.SongPatch
+	for i, op := range ops {
+		name, ok := strings.CutPrefix(op.Path, "/")
+		if !ok || strings.Contains(name, "/") {
+			return models.SongPatch{}, fmt.Errorf("%w: operation %d: unsupported path %q", models.ErrValidation, i, op.Path)
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				return models.SongPatch{}, fmt.Errorf("%w: operation %d: value is required", models.ErrValidation, i)
+			}
+			if name == "id" {
+				if err := checkPatchID(op.Value, id); err != nil {
+					return models.SongPatch{}, err
+				}
+				continue
+			}
+			if err := setPatchField(&patch, name, op.Value); err != nil {
+				return models.SongPatch{}, err
+			}
+		case "remove":
+			if err := setPatchField(&patch, name, nil); err != nil {
+				return models.SongPatch{}, err
+			}
+		default:
+			return models.SongPatch{}, fmt.Errorf("%w: operation %d: unsupported op %q", models.ErrValidation, i, op.Op)
+		}
+	}
+
+	return patch, nil
+}
+
+// parsePatch picks the patch format by the request Content-Type, plain
+// application/json is treated as a merge patch
+func parsePatch(r *http.Request, body []byte, id int) (models.SongPatch, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case contentTypeJSONPatch:
+		return parseJSONPatch(body, id)
+	case contentTypeMergePatch, "application/json", "":
+		return parseMergePatch(body, id)
+	}
+
+	return models.SongPatch{}, errUnsupportedMediaType
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// RefreshSong asks the music info API about a song again
+// @Summary Refresh song details from the music info API
+// @Description Fetches the text, link and release date of the song again, bypassing the details cache, and returns the fields whose upstream value differs from the stored one. apply=none only previews the differences, missing fills in the fields the song has no value for, all overwrites every differing field. Fields the API has no value for are left alone. The API has no chords, so the text of a ChordPro song is only replaced with overwrite_chordpro=true and then becomes plain. An applied refresh marks the enrichment of the song as done.
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song Id"
+// @Param apply query string false "Which differences to store" Enums(none, missing, all) default(none)
+// @Param overwrite_chordpro query bool false "Replace the text of a ChordPro song with the plain upstream text" default(false)
+// @Success 200 {object} models.SongRefresh "Differences, applied ones are marked"
+// @Failure 400 {object} Response "Invalid id"
+// @Failure 404 {object} Response "Song not found"
+// @Failure 422 {object} Response "Invalid apply"
+// @Failure 502 {object} Response "Music info API failed"
+// @Failure 500 {object} Response "Failed to refresh song"
+// @Router /songs/{id}/refresh [post]
+func (h *Handlers) RefreshSong(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid songID"), http.StatusBadRequest)
+		return
+	}
+
+	overwrite, err := overwriteChordPro(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid overwrite_chordpro parameter"), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Service.Refresh(r.Context(), id, r.URL.Query().Get("apply"), overwrite)
+	if err != nil {
+		h.fail(w, err, "can't refresh song")
+		return
+	}
+
+	h.response(w, SendSuccess(res), http.StatusOK)
+}
+
+// RefreshGroupSongs asks the music info API about a page of the songs of a
+// group again
+// @Summary Refresh the songs of a group
+// @Description Refreshes a page of the songs of the group like POST /songs/{id}/refresh. A song the API fails for, that was deleted meanwhile or got invalid details is reported with its error instead of failing the whole page.
+// @Tags groups
+// @Produce  json
+// @Param id path int true "Group Id"
+// @Param apply query string false "Which differences to store" Enums(none, missing, all) default(none)
+// @Param overwrite_chordpro query bool false "Replace the text of ChordPro songs with the plain upstream text" default(false)
+// @Param limit query int false "Limit" default(10)
+// @Param offset query int false "Offset"
+// @Success 200 {array} models.SongRefresh "Refreshed songs, meta describes the page"
+// @Header 200 {string} Link "first, prev, next and last pages"
+// @Failure 400 {object} Response "Invalid input"
+// @Failure 404 {object} Response "Group not found"
+// @Failure 422 {object} Response "Invalid apply"
+// @Failure 500 {object} Response "Failed to refresh songs"
+// @Router /groups/{id}/refresh [post]
+func (h *Handlers) RefreshGroupSongs(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid group id"), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, ok := h.limitOffset(w, r)
+	if !ok {
+		return
+	}
+
+	overwrite, err := overwriteChordPro(r)
+	if err != nil {
+		h.response(w, SendError(codeBadRequest, "invalid overwrite_chordpro parameter"), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.Service.RefreshGroup(r.Context(), id, r.URL.Query().Get("apply"), overwrite, limit, offset)
+	if err != nil {
+		h.fail(w, err, "can't refresh group songs")
+		return
+	}
+
+	resp := SendSuccess(page.Refreshes)
+	resp.Meta = paginate(w, r, Meta{
+		Total:   page.Total,
+		Limit:   limit,
+		Offset:  offset,
+		HasNext: page.HasNext,
+	}, offsetPage(limit))
+
+	h.response(w, resp, http.StatusOK)
+}
+
+// overwriteChordPro reads the overwrite_chordpro query parameter, false when
+// it is absent
+func overwriteChordPro(r *http.Request) (bool, error) {
+	val := r.URL.Query().Get("overwrite_chordpro")
+	if val == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(val)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
 )
 
@@ -13,7 +14,22 @@ const (
 type Response struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
 	Result  any    `json:"result"`
+	Meta    *Meta  `json:"meta,omitempty"`
+}
+
+// Meta describes the page a list response holds
+type Meta struct {
+	// Total counts the items of all pages
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	// Page is the 1-based page number, unknown for cursor pages
+	Page    int  `json:"page,omitempty"`
+	HasNext bool `json:"has_next"`
+	// NextCursor continues the listing after this page, empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 func SendSuccess(result any) Response {
@@ -23,10 +39,11 @@ func SendSuccess(result any) Response {
 	}
 }
 
-func SendError(msg string) Response {
+func SendError(code, msg string) Response {
 	return Response{
 		Status:  statusErr,
 		Message: msg,
+		Code:    code,
 	}
 }
 
@@ -34,8 +51,8 @@ func (h *Handlers) response(w http.ResponseWriter, r Response, statusCode int) {
 	data, err := json.Marshal(r)
 	if err != nil {
 		msg := "can't marshal response"
-		h.log.Error(msg, ", err=", err)
-		r = SendError(msg)
+		h.log.Error(msg, slog.Any("error", err))
+		data, _ = json.Marshal(SendError(codeInternal, msg))
 		statusCode = http.StatusInternalServerError
 	}
 
@@ -0,0 +1,181 @@
+// Package lrc reads and writes timed lyrics in the LRC format.
+package lrc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoLines = errors.New("no timed lines")
+
+	// [mm:ss], [mm:ss.x], [mm:ss.xx], [mm:ss.xxx] and the [mm:ss:xx] variant
+	timeRe = regexp.MustCompile(`^(\d+):(\d{1,2})(?:[.:](\d{1,3}))?$`)
+	tagRe  = regexp.MustCompile(`^([A-Za-z#]+):(.*)$`)
+)
+
+// tagOrder is the order ID tags are written in, unknown tags follow sorted
+var tagOrder = []string{"ti", "ar", "al", "au", "by", "length", "re", "ve"}
+
+// Parse reads an LRC file. A line may carry several timestamps and is then
+// repeated at each of them, lines come out sorted by time. Lines without a
+// timestamp and unknown bracket tags are skipped.
+func Parse(r io.Reader) (models.TimedLyrics, error) {
+	lyrics := models.TimedLyrics{Meta: map[string]string{}, Lines: []models.TimedLine{}}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		var times []int64
+		for strings.HasPrefix(line, "[") {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return models.TimedLyrics{}, fmt.Errorf("line %d: unclosed tag", n)
+			}
+			tag := line[1:end]
+
+			if m := timeRe.FindStringSubmatch(tag); m != nil {
+				t, err := timestamp(m)
+				if err != nil {
+					return models.TimedLyrics{}, fmt.Errorf("line %d: %w", n, err)
+				}
+				times = append(times, t)
+				line = line[end+1:]
+				continue
+			}
+
+			// an ID tag takes the whole line
+			if m := tagRe.FindStringSubmatch(tag); m != nil && len(times) == 0 && strings.TrimSpace(line[end+1:]) == "" {
+				if err := idTag(&lyrics, strings.ToLower(m[1]), strings.TrimSpace(m[2])); err != nil {
+					return models.TimedLyrics{}, fmt.Errorf("line %d: %w", n, err)
+				}
+			}
+			break
+		}
+
+		text := strings.TrimSpace(line)
+		for _, t := range times {
+			lyrics.Lines = append(lyrics.Lines, models.TimedLine{Time: t, Text: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return models.TimedLyrics{}, err
+	}
+
+	if len(lyrics.Lines) == 0 {
+		return models.TimedLyrics{}, ErrNoLines
+	}
+	if len(lyrics.Meta) == 0 {
+		lyrics.Meta = nil
+	}
+
+	sort.SliceStable(lyrics.Lines, func(i, j int) bool {
+		return lyrics.Lines[i].Time < lyrics.Lines[j].Time
+	})
+
+	return lyrics, nil
+}
+
+// timestamp converts a match of timeRe into milliseconds
+func timestamp(m []string) (int64, error) {
+	minutes, _ := strconv.ParseInt(m[1], 10, 64)
+	sec, _ := strconv.ParseInt(m[2], 10, 64)
+	if sec >= 60 {
+		return 0, fmt.Errorf("invalid timestamp %s:%s", m[1], m[2])
+	}
+
+	// the fraction is tenths, hundredths or thousandths by its length
+	var ms int64
+	if frac := m[3]; frac != "" {
+		ms, _ = strconv.ParseInt(frac+strings.Repeat("0", 3-len(frac)), 10, 64)
+	}
+
+	return (minutes*60+sec)*1000 + ms, nil
+}
+
+func idTag(lyrics *models.TimedLyrics, name, value string) error {
+	if name != "offset" {
+		lyrics.Meta[name] = value
+		return nil
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimPrefix(value, "+"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid offset %q", value)
+	}
+	lyrics.Offset = offset
+
+	return nil
+}
+
+// Format writes lyrics as an LRC file
+func Format(lyrics models.TimedLyrics) string {
+	var b strings.Builder
+
+	tags := make([]string, 0, len(lyrics.Meta))
+	for name := range lyrics.Meta {
+		if !slices.Contains(tagOrder, name) {
+			tags = append(tags, name)
+		}
+	}
+	sort.Strings(tags)
+
+	for _, name := range append(slices.Clone(tagOrder), tags...) {
+		if value, ok := lyrics.Meta[name]; ok {
+			fmt.Fprintf(&b, "[%s:%s]\n", name, value)
+		}
+	}
+	if lyrics.Offset != 0 {
+		fmt.Fprintf(&b, "[offset:%+d]\n", lyrics.Offset)
+	}
+
+	for _, line := range lyrics.Lines {
+		fmt.Fprintf(&b, "[%s]%s\n", formatTime(line.Time), line.Text)
+	}
+
+	return b.String()
+}
+
+// formatTime writes ms as mm:ss.xx, or mm:ss.xxx when hundredths would lose
+// precision
+func formatTime(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	minutes, sec := int64(d/time.Minute), int64(d%time.Minute/time.Second)
+
+	if frac := ms % 1000; frac%10 != 0 {
+		return fmt.Sprintf("%02d:%02d.%03d", minutes, sec, frac)
+	}
+
+	return fmt.Sprintf("%02d:%02d.%02d", minutes, sec, ms%1000/10)
+}
+
+// At returns the line active at the playback position t, the last line that
+// started at or before t
+func At(lyrics models.TimedLyrics, t time.Duration) models.LyricsPosition {
+	i := sort.Search(len(lyrics.Lines), func(i int) bool {
+		return lyrics.Start(i) > t
+	})
+
+	pos := models.LyricsPosition{Index: i - 1}
+	if i > 0 {
+		pos.Line = &lyrics.Lines[i-1]
+	}
+	if i < len(lyrics.Lines) {
+		pos.Next = &lyrics.Lines[i]
+	}
+
+	return pos
+}
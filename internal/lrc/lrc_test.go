@@ -0,0 +1,69 @@
+package lrc_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/lrc"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const file = "\ufeff[ti:Yesterday]\r\n[ar:The Beatles]\n[length: 02:05]\n[offset:+500]\n\n" +
+	"[00:10.5]Yesterday\n[00:12.34][01:02:34]All my troubles seemed so far away\n" +
+	"[00:15.123]\nno timestamp here\n[re:some editor]\n"
+
+func TestParse(t *testing.T) {
+	lyrics, err := lrc.Parse(strings.NewReader(file))
+	assert.Nil(t, err)
+
+	assert.Equal(t, models.TimedLyrics{
+		Meta:   map[string]string{"ti": "Yesterday", "ar": "The Beatles", "length": "02:05", "re": "some editor"},
+		Offset: 500,
+		Lines: []models.TimedLine{
+			{Time: 10500, Text: "Yesterday"},
+			{Time: 12340, Text: "All my troubles seemed so far away"},
+			{Time: 15123, Text: ""},
+			{Time: 62340, Text: "All my troubles seemed so far away"},
+		},
+	}, lyrics)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{"", "plain text\n", "[00:75.00]bad seconds", "[offset:soon]\n[00:01.00]a", "[00:01.00"} {
+		_, err := lrc.Parse(strings.NewReader(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	lyrics, err := lrc.Parse(strings.NewReader(file))
+	assert.Nil(t, err)
+
+	out := lrc.Format(lyrics)
+	assert.Equal(t, "[ti:Yesterday]\n[ar:The Beatles]\n[length:02:05]\n[re:some editor]\n[offset:+500]\n"+
+		"[00:10.50]Yesterday\n[00:12.34]All my troubles seemed so far away\n[00:15.123]\n[01:02.34]All my troubles seemed so far away\n", out)
+
+	again, err := lrc.Parse(strings.NewReader(out))
+	assert.Nil(t, err)
+	assert.Equal(t, lyrics, again)
+}
+
+func TestAt(t *testing.T) {
+	lyrics := models.TimedLyrics{Offset: 500, Lines: []models.TimedLine{{Time: 1000, Text: "one"}, {Time: 3000, Text: "two"}}}
+
+	pos := lrc.At(lyrics, 0)
+	assert.Equal(t, -1, pos.Index)
+	assert.Nil(t, pos.Line)
+	assert.Equal(t, "one", pos.Next.Text)
+
+	// the offset shows lines earlier
+	pos = lrc.At(lyrics, 500*time.Millisecond)
+	assert.Equal(t, 0, pos.Index)
+	assert.Equal(t, "one", pos.Line.Text)
+
+	pos = lrc.At(lyrics, time.Minute)
+	assert.Equal(t, 1, pos.Index)
+	assert.Nil(t, pos.Next)
+}
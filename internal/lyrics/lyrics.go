@@ -0,0 +1,171 @@
+// Package lyrics parses song text with optional section markup such as
+// [Chorus] or [Verse 2] into typed sections.
+package lyrics
+
+import (
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	headerRe = regexp.MustCompile(`^\[([^\[\]]+)\]$`)
+	numberRe = regexp.MustCompile(`\d+`)
+)
+
+// sectionKeywords maps header words to section types, longer keywords first
+// so "pre-chorus" isn't taken for "chorus"
+var sectionKeywords = []struct {
+	keyword string
+	typ     string
+}{
+	{"pre-chorus", models.SectionPreChorus},
+	{"pre chorus", models.SectionPreChorus},
+	{"prechorus", models.SectionPreChorus},
+	{"предприпев", models.SectionPreChorus},
+	{"chorus", models.SectionChorus},
+	{"refrain", models.SectionChorus},
+	{"припев", models.SectionChorus},
+	{"verse", models.SectionVerse},
+	{"куплет", models.SectionVerse},
+	{"bridge", models.SectionBridge},
+	{"бридж", models.SectionBridge},
+	{"intro", models.SectionIntro},
+	{"вступление", models.SectionIntro},
+	{"outro", models.SectionOutro},
+	{"концовка", models.SectionOutro},
+	{"hook", models.SectionHook},
+}
+
+// IsType reports whether typ is a known section type
+func IsType(typ string) bool {
+	switch typ {
+	case models.SectionVerse, models.SectionPreChorus, models.SectionChorus, models.SectionBridge,
+		models.SectionIntro, models.SectionOutro, models.SectionHook, models.SectionOther:
+		return true
+	}
+
+	return false
+}
+
+// Parse splits text into sections. A header line like [Chorus] starts a
+// section and a blank line ends it. Stanzas without a header are verses, so
+// text without markup parses into one verse per stanza. A header directly
+// followed by a blank line, another header or the end repeats the latest
+// section of the same type and number.
+func Parse(text string) models.Sections {
+	sections := models.Sections{}
+
+	var (
+		cur    *models.Section
+		verses int
+	)
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		cur.Repeat = len(cur.Lines) == 0
+		if cur.Repeat {
+			cur.Lines = []string{}
+		}
+		if cur.Type == models.SectionVerse && cur.Number == 0 && !cur.Repeat {
+			cur.Number = verses + 1
+		}
+		if cur.Type == models.SectionVerse && !cur.Repeat {
+			verses = max(verses, cur.Number)
+		}
+		sections = append(sections, *cur)
+		cur = nil
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			flush()
+			continue
+		}
+
+		if m := headerRe.FindStringSubmatch(trimmed); m != nil {
+			flush()
+			section := header(m[1])
+			cur = &section
+			continue
+		}
+
+		if cur == nil {
+			cur = &models.Section{Type: models.SectionVerse}
+		}
+		cur.Lines = append(cur.Lines, line)
+	}
+	flush()
+
+	return sections
+}
+
+// header turns the text of a header line into an empty section
+func header(label string) models.Section {
+	label = strings.TrimSpace(label)
+	section := models.Section{Type: models.SectionOther, Label: label}
+
+	lower := strings.ToLower(label)
+	for _, k := range sectionKeywords {
+		if strings.HasPrefix(lower, k.keyword) {
+			section.Type = k.typ
+			// the number follows the keyword, "Verse 2: Artist" is verse 2
+			if n := numberRe.FindString(lower[len(k.keyword):]); n != "" {
+				section.Number, _ = strconv.Atoi(n)
+			}
+			break
+		}
+	}
+
+	return section
+}
+
+// Expand fills the lines of repeated sections from the section they repeat.
+// Repeats of a section that never had lines stay empty.
+func Expand(sections models.Sections) models.Sections {
+	expanded := make(models.Sections, 0, len(sections))
+
+	for _, s := range sections {
+		if s.Repeat {
+			for i := len(expanded) - 1; i >= 0; i-- {
+				prev := expanded[i]
+				if prev.Type == s.Type && (s.Number == 0 || prev.Number == s.Number) && len(prev.Lines) > 0 {
+					s.Lines = prev.Lines
+					break
+				}
+			}
+		}
+		expanded = append(expanded, s)
+	}
+
+	return expanded
+}
+
+// Filter keeps the sections of the given types, all of them when types is empty
+func Filter(sections models.Sections, types []string) models.Sections {
+	if len(types) == 0 {
+		return sections
+	}
+
+	filtered := models.Sections{}
+	for _, s := range sections {
+		if Matches(s, types) {
+			filtered = append(filtered, s)
+		}
+	}
+
+	return filtered
+}
+
+// Matches reports whether the section is of one of types, an empty types
+// matches any section
+func Matches(section models.Section, types []string) bool {
+	return len(types) == 0 || slices.Contains(types, section.Type)
+}
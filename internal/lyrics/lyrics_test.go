@@ -0,0 +1,44 @@
+package lyrics_test
+
+import (
+	"testing"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/lyrics"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	text := "[Intro]\nooh\n\n[Verse 1: Singer]\nfirst line\nsecond line\n\n[Pre-Chorus]\nbuild up\n[Chorus]\nla la la\n\n" +
+		"untitled stanza\n\n[Припев]\n\n[Bridge]\nbridge line\n\n[Chorus]\n"
+
+	assert.Equal(t, models.Sections{
+		{Type: models.SectionIntro, Label: "Intro", Lines: []string{"ooh"}},
+		{Type: models.SectionVerse, Label: "Verse 1: Singer", Number: 1, Lines: []string{"first line", "second line"}},
+		{Type: models.SectionPreChorus, Label: "Pre-Chorus", Lines: []string{"build up"}},
+		{Type: models.SectionChorus, Label: "Chorus", Lines: []string{"la la la"}},
+		{Type: models.SectionVerse, Number: 2, Lines: []string{"untitled stanza"}},
+		{Type: models.SectionChorus, Label: "Припев", Lines: []string{}, Repeat: true},
+		{Type: models.SectionBridge, Label: "Bridge", Lines: []string{"bridge line"}},
+		{Type: models.SectionChorus, Label: "Chorus", Lines: []string{}, Repeat: true},
+	}, lyrics.Parse(text))
+}
+
+func TestParseWithoutMarkup(t *testing.T) {
+	sections := lyrics.Parse("first verse\n\nsecond verse\n\n")
+
+	assert.Equal(t, models.Sections{
+		{Type: models.SectionVerse, Number: 1, Lines: []string{"first verse"}},
+		{Type: models.SectionVerse, Number: 2, Lines: []string{"second verse"}},
+	}, sections)
+}
+
+func TestExpand(t *testing.T) {
+	sections := lyrics.Expand(lyrics.Parse("[Chorus]\nla la\n\n[Verse]\nwords\n\n[Chorus]\n\n[Solo]\n"))
+
+	assert.Equal(t, []string{"la la"}, sections[2].Lines)
+	assert.True(t, sections[2].Repeat)
+	// nothing to repeat
+	assert.Equal(t, models.SectionOther, sections[3].Type)
+	assert.Empty(t, sections[3].Lines)
+}
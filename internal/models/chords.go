@@ -0,0 +1,63 @@
+package models
+
+// Formats of Song.Text
+const (
+	// TextFormatPlain is lyrics with optional [Chorus] style section markup
+	TextFormatPlain = "plain"
+	// TextFormatChordPro is ChordPro, lyrics with [G] chords inline and
+	// {directives} on lines of their own
+	TextFormatChordPro = "chordpro"
+)
+
+// Notations chord names are written in
+const (
+	// NotationLatin is C D E F G A B with # and b
+	NotationLatin = "latin"
+	// NotationGerman writes B as H and Bb as B
+	NotationGerman = "german"
+	// NotationSolfege is Do Re Mi Fa Sol La Si
+	NotationSolfege = "solfege"
+)
+
+// SectionTab is the type of a tablature block, it only occurs in chord sheets
+const SectionTab = "tab"
+
+// ChordSheet is a song in ChordPro format parsed into sections of lines with
+// chords
+type ChordSheet struct {
+	Title    string `json:"title,omitempty"`
+	Subtitle string `json:"subtitle,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Capo     int    `json:"capo,omitempty"`
+	// Meta holds the other directives with a value, e.g. {tempo: 120}
+	Meta     map[string]string `json:"meta,omitempty"`
+	Sections []ChordSection    `json:"sections"`
+}
+
+// ChordSection is a paragraph of a chord sheet or a block between directives
+// like {start_of_chorus} and {end_of_chorus}
+type ChordSection struct {
+	// Type is one of the Section* constants
+	Type  string      `json:"type"`
+	Label string      `json:"label,omitempty"`
+	Lines []ChordLine `json:"lines"`
+	// Repeat marks a {chorus} directive that repeats the latest chorus
+	Repeat bool `json:"repeat,omitempty"`
+}
+
+// ChordLine is a line of lyrics with the chords played over it
+type ChordLine struct {
+	Text   string          `json:"text"`
+	Chords []ChordPosition `json:"chords,omitempty"`
+	// Comment marks a {comment} directive, Text is the comment
+	Comment bool `json:"comment,omitempty"`
+}
+
+// ChordPosition is a chord and the character of the line it is played over
+type ChordPosition struct {
+	// Offset counts characters, not bytes, from the start of the line text.
+	// It equals the text length for a chord after the last word.
+	Offset int    `json:"offset"`
+	Chord  string `json:"chord"`
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Date layouts accepted by the API
+const (
+	// LegacyDateLayout is the DD.MM.YYYY layout used by the music info API
+	LegacyDateLayout = "02.01.2006"
+	// ISODateLayout is the ISO-8601 calendar date layout
+	ISODateLayout = time.DateOnly
+)
+
+// Date is a calendar date stored in a postgres DATE column. It is parsed from
+// either LegacyDateLayout or ISODateLayout and rendered in LegacyDateLayout
+// unless another layout is picked with In. The zero Date means unknown and is
+// stored as NULL.
+type Date struct {
+	t      time.Time
+	layout string
+}
+
+// NewDate returns the Date of the given day
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{t: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate parses s in LegacyDateLayout, ISODateLayout or RFC 3339, the
+// empty string is the zero Date
+func ParseDate(s string) (Date, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Date{}, nil
+	}
+
+	for _, layout := range []string{LegacyDateLayout, ISODateLayout, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return NewDate(t.Date()), nil
+		}
+	}
+
+	return Date{}, fmt.Errorf("invalid date %q, expected %s or %s", s, LegacyDateLayout, ISODateLayout)
+}
+
+// Time returns the date as midnight UTC
+func (d Date) Time() time.Time {
+	return d.t
+}
+
+func (d Date) IsZero() bool {
+	return d.t.IsZero()
+}
+
+// Before reports whether d is before other
+func (d Date) Before(other Date) bool {
+	return d.t.Before(other.t)
+}
+
+// Equal reports whether both values are the same day regardless of layout
+func (d Date) Equal(other Date) bool {
+	return d.t.Equal(other.t)
+}
+
+// In returns a copy of d rendered with the given layout
+func (d Date) In(layout string) Date {
+	d.layout = layout
+	return d
+}
+
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	if d.layout == "" {
+		return d.t.Format(LegacyDateLayout)
+	}
+
+	return d.t.Format(d.layout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Date{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("date must be a string: %w", err)
+	}
+
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+
+	return nil
+}
+
+// Scan implements sql.Scanner
+func (d *Date) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+	case time.Time:
+		*d = NewDate(v.Date())
+	case string:
+		return d.scanString(v)
+	case []byte:
+		return d.scanString(string(v))
+	default:
+		return fmt.Errorf("can't scan %T into Date", src)
+	}
+
+	return nil
+}
+
+func (d *Date) scanString(s string) error {
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+
+	return nil
+}
+
+// Value implements driver.Valuer, the zero Date is stored as NULL
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+
+	return d.t.Format(ISODateLayout), nil
+}
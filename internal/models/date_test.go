@@ -0,0 +1,41 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDate(t *testing.T) {
+	want := models.NewDate(2006, time.January, 2)
+
+	for _, in := range []string{"02.01.2006", "2006-01-02", "2006-01-02T15:04:05Z"} {
+		got, err := models.ParseDate(in)
+		assert.Nil(t, err, in)
+		assert.True(t, want.Equal(got), in)
+	}
+
+	_, err := models.ParseDate("2 Jan 2006")
+	assert.Error(t, err)
+}
+
+func TestDateJSON(t *testing.T) {
+	var song models.Song
+	err := json.Unmarshal([]byte(`{"releasedate":"2006-01-02"}`), &song)
+	assert.Nil(t, err)
+
+	data, err := json.Marshal(song.ReleaseDate)
+	assert.Nil(t, err)
+	assert.Equal(t, `"02.01.2006"`, string(data))
+
+	data, err = json.Marshal(song.ReleaseDate.In(models.ISODateLayout))
+	assert.Nil(t, err)
+	assert.Equal(t, `"2006-01-02"`, string(data))
+
+	data, err = json.Marshal(models.Date{})
+	assert.Nil(t, err)
+	assert.Equal(t, `""`, string(data))
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Statuses of the enrichment of a song
+const (
+	// EnrichmentPending songs wait for a worker
+	EnrichmentPending = "pending"
+	EnrichmentDone    = "done"
+	// EnrichmentFailed songs ran out of attempts or got an answer that
+	// retrying won't change
+	EnrichmentFailed = "failed"
+	// EnrichmentSkipped songs were stored while enrichment was turned off or
+	// with all the fields set
+	EnrichmentSkipped = "skipped"
+)
+
+// Enrichment is the state of filling in the song details from the music info
+// API in the background
+type Enrichment struct {
+	Status string `json:"status" enums:"pending,done,failed,skipped"`
+	// Attempts counts the finished attempts so far
+	Attempts int `json:"attempts"`
+	// LastError is the error of the latest failed attempt
+	LastError string `json:"last_error,omitempty"`
+	// NextAttemptAt is when a pending song is tried next
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	// Sources names the provider each enriched field came from
+	Sources FieldSources `json:"sources,omitempty"`
+}
+
+// Ways upstream details are merged into a stored song in the background
+const (
+	// MergeUserWins fills in every field but the ones the user set, even to
+	// an empty value
+	MergeUserWins = "user_wins"
+	// MergeUpstreamWins overwrites every field the upstream has a value for
+	MergeUpstreamWins = "upstream_wins"
+	// MergeFillMissing only fills in the fields that are empty
+	MergeFillMissing = "fill_missing"
+)
+
+// FieldSet is a set of the text, link and releasedate field names, stored
+// as a jsonb array
+type FieldSet []string
+
+// Has reports whether the set holds field
+func (f FieldSet) Has(field string) bool {
+	return slices.Contains(f, field)
+}
+
+func (f *FieldSet) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	case []byte:
+		return json.Unmarshal(v, f)
+	}
+
+	return fmt.Errorf("can't scan %T into FieldSet", src)
+}
+
+func (f FieldSet) Value() (driver.Value, error) {
+	if f == nil {
+		return "[]", nil
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// FieldSources maps the text, link and releasedate fields to the name of the
+// provider that supplied them, stored as jsonb
+type FieldSources map[string]string
+
+func (f *FieldSources) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	case []byte:
+		return json.Unmarshal(v, f)
+	}
+
+	return fmt.Errorf("can't scan %T into FieldSources", src)
+}
+
+func (f FieldSources) Value() (driver.Value, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// EnrichmentJob is a pending song claimed by an enrichment worker
+type EnrichmentJob struct {
+	ID        int
+	Song      string
+	GroupName string
+	Attempts  int
+	// Complete is set when the song has a text, a link and a release date
+	Complete bool
+	// ClaimedUntil is when the claim of the worker runs out, the outcome is
+	// only stored while the song is still claimed until then
+	ClaimedUntil time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "errors"
+
+// Domain errors shared by the storage and service layers. They are wrapped
+// with details via fmt.Errorf("...%w", ...) and matched with errors.Is by the
+// handlers, which translate them into HTTP status codes.
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrConflict       = errors.New("conflict")
+	ErrValidation     = errors.New("validation failed")
+	ErrUpstream       = errors.New("upstream enrichment failed")
+	ErrPageOutOfRange = errors.New("page out of range")
+)
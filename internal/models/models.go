@@ -1,15 +1,166 @@
 package models
 
 type Song struct {
-	ID          int    `json:"id"`
-	GroupName   string `json:"group_name"`
-	Song        string `json:"song"`
-	Text        string `json:"text"`
+	ID        int    `json:"id"`
+	GroupID   int    `json:"group_id,omitempty"`
+	GroupName string `json:"group_name"`
+	Song      string `json:"song"`
+	Text      string `json:"text"`
+	// TextFormat tells how Text is written, one of the TextFormat* constants
+	TextFormat  string `json:"text_format" enums:"plain,chordpro" default:"plain"`
 	Link        string `json:"link"`
-	ReleaseDate string `json:"releasedate"`
+	ReleaseDate Date   `json:"releasedate" swaggertype:"string" example:"02.01.2006"`
+	// Score is the similarity to the filter, only set for fuzzy matches
+	Score *float64 `json:"score,omitempty"`
+	// Sections is Text parsed into typed sections, served by its own endpoint
+	Sections Sections `json:"-"`
+	// TimedLyrics are the synced lines imported from an LRC file, if any
+	TimedLyrics *TimedLyrics `json:"-"`
+	// Enrichment is the state of the background enrichment, served by its own
+	// endpoint
+	Enrichment *Enrichment `json:"-"`
+	// Sources names the provider of each field of upstream details that were
+	// merged from several providers
+	Sources FieldSources `json:"-"`
+	// UserFields are the enrichable fields the user set, see MergeUserWins
+	UserFields FieldSet `json:"-"`
+}
+
+// Verse is a stanza of the lyrics, stanzas are separated by blank lines or
+// section headers
+type Verse struct {
+	// Number is the 1-based position of the verse in the song
+	Number int `json:"number"`
+	// Type is the section type of the verse, see the Section* constants
+	Type  string   `json:"type"`
+	Lines []string `json:"lines"`
 }
 
 type Group struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
 }
+
+// What to do when a group is renamed to a name another group already has
+const (
+	// OnConflictError rejects the rename
+	OnConflictError = "error"
+	// OnConflictMerge moves all songs into the existing group and removes the renamed one
+	OnConflictMerge = "merge"
+)
+
+// GroupRename renames a group for all of its songs
+type GroupRename struct {
+	Name       string `json:"name"`
+	OnConflict string `json:"on_conflict,omitempty" enums:"error,merge" default:"error"`
+}
+
+// SongPatch describes a partial song update, nil fields are left untouched
+type SongPatch struct {
+	GroupName *string
+	// GroupID is resolved from GroupName by the service before storing
+	GroupID *int
+	Song    *string
+	Text    *string
+	// TextFormat is the format of Text, plain when cleared
+	TextFormat *string
+	// Sections is parsed from Text by the service before storing
+	Sections    *Sections
+	Link        *string
+	ReleaseDate *Date
+	// UserFields are added to the fields set by the user, the service fills
+	// them in for a patch made by the user
+	UserFields FieldSet
+}
+
+// IsEmpty reports whether the patch changes nothing
+func (p SongPatch) IsEmpty() bool {
+	return p.GroupName == nil && p.Song == nil && p.Text == nil && p.TextFormat == nil && p.Link == nil && p.ReleaseDate == nil
+}
+
+// Ways SongFilter.Song and SongFilter.GroupName are matched
+const (
+	MatchExact    = "exact"
+	MatchPrefix   = "prefix"
+	MatchContains = "contains"
+	MatchFuzzy    = "fuzzy"
+)
+
+// Fields songs can be sorted by
+const (
+	SortByID          = "id"
+	SortBySong        = "song"
+	SortByGroupName   = "group_name"
+	SortByReleaseDate = "releasedate"
+)
+
+// SortField is one key of a sort order
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// SongFilter selects songs, zero fields don't filter
+type SongFilter struct {
+	ID        int
+	GroupID   int
+	GroupName string
+	Song      string
+	// Match is one of the Match* constants, exact when empty
+	Match string
+	// ReleaseDate matches the exact day
+	ReleaseDate Date
+	// ReleasedFrom and ReleasedTo bound the release date, both inclusive
+	ReleasedFrom Date
+	ReleasedTo   Date
+	Year         int
+	// Sort orders the songs, ties are always broken by id
+	Sort []SortField
+	// After continues a keyset pagination, it replaces Offset
+	After  *SongCursor
+	Limit  int
+	Offset int
+}
+
+// SongPage is one page of a song listing
+type SongPage struct {
+	Songs []Song
+	// Total counts all songs matching the filter, regardless of the page
+	Total int
+	// HasNext tells whether more songs follow this page
+	HasNext bool
+}
+
+// SongCursor holds the sort keys of the last song of a page, the next page
+// starts right after it
+type SongCursor struct {
+	Sort        []SortField
+	ID          int
+	Song        string
+	GroupName   string
+	ReleaseDate Date
+}
+
+// Languages supported by the lyrics search
+const (
+	SearchLangEnglish = "en"
+	SearchLangRussian = "ru"
+)
+
+// SearchQuery is a full-text search over song titles, group names and lyrics
+type SearchQuery struct {
+	Query string
+	// Language restricts the search to one text search configuration, empty
+	// searches both
+	Language string
+	Limit    int
+	Offset   int
+}
+
+// SongSearchResult is a song matched by a full-text search
+type SongSearchResult struct {
+	Song
+	Rank float64 `json:"rank"`
+	// Snippet is a fragment of the lyrics with matches wrapped in <b></b>
+	Snippet string `json:"snippet"`
+}
@@ -0,0 +1,51 @@
+package models
+
+// Ways a refresh applies the upstream details to the stored song
+const (
+	// RefreshApplyNone only previews the differences
+	RefreshApplyNone = "none"
+	// RefreshApplyMissing fills in the fields the song has no value for
+	RefreshApplyMissing = "missing"
+	// RefreshApplyAll overwrites every field the upstream has a value for
+	RefreshApplyAll = "all"
+)
+
+// Fields compared by a refresh
+const (
+	FieldText        = "text"
+	FieldLink        = "link"
+	FieldReleaseDate = "releasedate"
+)
+
+// FieldDiff is a field whose upstream value differs from the stored one.
+// Fields the upstream has no value for are never reported.
+type FieldDiff struct {
+	Field    string `json:"field" enums:"text,link,releasedate"`
+	Stored   string `json:"stored"`
+	Upstream string `json:"upstream"`
+	// Applied marks the fields overwritten with the upstream value
+	Applied bool `json:"applied"`
+	// Source is the provider the upstream value came from, when several are
+	// configured
+	Source string `json:"source,omitempty"`
+}
+
+// SongRefresh is the outcome of asking the upstream about a stored song again
+type SongRefresh struct {
+	ID        int         `json:"id"`
+	Song      string      `json:"song"`
+	GroupName string      `json:"group_name"`
+	Apply     string      `json:"apply" enums:"none,missing,all"`
+	Diff      []FieldDiff `json:"diff"`
+	// Error is why the song couldn't be refreshed, only a group refresh
+	// reports it per song instead of failing
+	Error string `json:"error,omitempty"`
+}
+
+// RefreshPage is a page of the refreshed songs of a group
+type RefreshPage struct {
+	Refreshes []SongRefresh
+	// Total is the number of songs in the group
+	Total   int
+	HasNext bool
+}
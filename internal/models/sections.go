@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Types of lyrics sections
+const (
+	SectionVerse     = "verse"
+	SectionPreChorus = "pre-chorus"
+	SectionChorus    = "chorus"
+	SectionBridge    = "bridge"
+	SectionIntro     = "intro"
+	SectionOutro     = "outro"
+	SectionHook      = "hook"
+	SectionOther     = "other"
+)
+
+// Section is a typed part of the lyrics, e.g. the one under a [Chorus] header
+type Section struct {
+	Type string `json:"type"`
+	// Label is the header as written in the lyrics, empty for unmarked stanzas
+	Label string `json:"label,omitempty"`
+	// Number tells apart sections of the same type, e.g. 2 for [Verse 2]
+	Number int      `json:"number,omitempty"`
+	Lines  []string `json:"lines"`
+	// Repeat marks a header without lines that repeats an earlier section
+	Repeat bool `json:"repeat,omitempty"`
+}
+
+// Sections is the structured form of the lyrics, stored as jsonb
+type Sections []Section
+
+func (s *Sections) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case []byte:
+		return json.Unmarshal(v, s)
+	}
+
+	return fmt.Errorf("can't scan %T into Sections", src)
+}
+
+func (s Sections) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
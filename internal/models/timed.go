@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TimedLine is a line of lyrics with the playback position it starts at
+type TimedLine struct {
+	// Time is the position in milliseconds as written in the file
+	Time int64  `json:"time_ms"`
+	Text string `json:"text"`
+}
+
+// TimedLyrics are lyrics synced to the playback, as imported from LRC
+type TimedLyrics struct {
+	// Meta holds the ID tags of the file such as ar, ti or al
+	Meta map[string]string `json:"meta,omitempty"`
+	// Offset in milliseconds, positive values show every line earlier
+	Offset int64       `json:"offset_ms,omitempty"`
+	Lines  []TimedLine `json:"lines"`
+}
+
+// Start returns the playback position line i becomes active at
+func (l TimedLyrics) Start(i int) time.Duration {
+	return time.Duration(l.Lines[i].Time-l.Offset) * time.Millisecond
+}
+
+func (l *TimedLyrics) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case []byte:
+		return json.Unmarshal(v, l)
+	}
+
+	return fmt.Errorf("can't scan %T into TimedLyrics", src)
+}
+
+func (l TimedLyrics) Value() (driver.Value, error) {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// LyricsPosition is the state of timed lyrics at a playback position
+type LyricsPosition struct {
+	// Index of the active line, -1 before the first line starts
+	Index int `json:"index"`
+	// Line is the active line, nil before the first line starts
+	Line *TimedLine `json:"line"`
+	// Next is the line that follows, nil after the last one
+	Next *TimedLine `json:"next,omitempty"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// UpstreamStatus is the state of the circuit breaker guarding the enrichment
+// API
+type UpstreamStatus struct {
+	// State is closed, open or half-open, disabled when enrichment is off
+	State string `json:"state" enums:"closed,open,half-open,disabled"`
+	// Failures counts the consecutive failed requests, of the provider with
+	// the fewest when there are several
+	Failures int `json:"failures"`
+	// RetryAt is when an open circuit lets the next request through
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+	// Providers are the states of each provider when several are configured,
+	// State is then the best of them
+	Providers []ProviderStatus `json:"providers,omitempty"`
+	// Cache is set when the details are cached in front of the client
+	Cache *CacheStats `json:"cache,omitempty"`
+}
+
+// ProviderStatus is the state of the circuit breaker of one provider
+type ProviderStatus struct {
+	Name     string     `json:"name"`
+	State    string     `json:"state" enums:"closed,open,half-open"`
+	Failures int        `json:"failures"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+// Ways the details of several providers are merged
+const (
+	// MergePriority takes each field from the first provider that has it
+	MergePriority = "priority"
+	// MergeFirst takes all fields from the first provider that answers
+	MergeFirst = "first"
+)
+
+// CacheStats are the counters of the enrichment details cache since the start
+type CacheStats struct {
+	// Entries is the number of cached lookups, Capacity their limit
+	Entries  int   `json:"entries"`
+	Capacity int   `json:"capacity"`
+	Hits     int64 `json:"hits"`
+	// NegativeHits are the hits answered with a cached not found
+	NegativeHits int64 `json:"negative_hits"`
+	Misses       int64 `json:"misses"`
+	// Shared counts the misses that joined a lookup already in flight
+	Shared int64 `json:"shared"`
+	// Evictions counts the entries dropped to make room, expired ones don't
+	// count
+	Evictions int64 `json:"evictions"`
+}
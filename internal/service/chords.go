@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/chordpro"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+)
+
+// Chords returns the chord sheet of a song written in ChordPro, transposed by
+// the given number of semitones and written in notation. Songs with plain
+// text have no chords and are reported as not found.
+func (s *Service) Chords(ctx context.Context, id int, transpose int, notation string) (models.ChordSheet, error) {
+	if transpose < -11 || transpose > 11 {
+		return models.ChordSheet{}, fmt.Errorf("%w: transpose must be between -11 and 11", models.ErrValidation)
+	}
+	if !chordpro.IsNotation(notation) {
+		return models.ChordSheet{}, fmt.Errorf("%w: notation must be %q, %q or %q", models.ErrValidation, models.NotationLatin, models.NotationGerman, models.NotationSolfege)
+	}
+
+	song, err := s.Repo.GetByID(ctx, id)
+	if err != nil {
+		return models.ChordSheet{}, err
+	}
+
+	if song.TextFormat != models.TextFormatChordPro {
+		return models.ChordSheet{}, fmt.Errorf("chords of song with ID %d %w", id, models.ErrNotFound)
+	}
+
+	sheet, err := chordpro.Parse(song.Text)
+	if err != nil {
+		return models.ChordSheet{}, fmt.Errorf("can't parse stored chordpro of song %d, err=%w", id, err)
+	}
+
+	return chordpro.Transpose(sheet, transpose, notation), nil
+}
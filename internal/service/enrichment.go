@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/Fyefhqdishka/eff-mobile/internal/client"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// EnrichmentConfig tunes the pool of workers enriching new songs in the
+// background
+type EnrichmentConfig struct {
+	Workers int
+	// BatchSize is the number of songs claimed from the database at once
+	BatchSize int
+	// PollInterval is how often the database is checked for due songs when
+	// no new song woke the pool up
+	PollInterval time.Duration
+	// Lease bounds a single enrichment, a song whose worker didn't report
+	// back by then, e.g. because of a restart, is claimed again
+	Lease time.Duration
+	// MaxAttempts failed attempts mark the song as failed
+	MaxAttempts int
+	// Backoff is the delay after the first failed attempt, doubled after each
+	// of them up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Merge is how the details are merged into the song, MergeUserWins,
+	// MergeUpstreamWins or MergeFillMissing
+	Merge string
+}
+
+// leaseMargin is the part of the lease, 1/leaseMargin of it, an enrichment
+// job keeps free to store its outcome before the claim runs out
+const leaseMargin = 10
+
+// StartEnrichment runs the enrichment pool until ctx is done and returns a
+// function waiting for its workers to stop. The pending songs live in the
+// database, so the songs left over by a previous run are picked up first.
+func (s *Service) StartEnrichment(ctx context.Context, cfg EnrichmentConfig) (wait func()) {
+	s.log.Info("starting enrichment",
+		slog.Int("workers", cfg.Workers),
+		slog.Int("max_attempts", cfg.MaxAttempts))
+
+	jobs := make(chan models.EnrichmentJob)
+
+	var wg sync.WaitGroup
+	for range cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				s.enrich(ctx, cfg, job)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.dispatch(ctx, cfg, jobs)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+
+	return wg.Wait
+}
+
+// wakeEnrichment makes the pool look for due songs without waiting for the
+// next poll
+func (s *Service) wakeEnrichment() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands the due songs to the workers, batch by batch until none are
+// left. Songs claimed but not handed out before ctx is done keep their lease
+// and are claimed again later.
+func (s *Service) dispatch(ctx context.Context, cfg EnrichmentConfig, jobs chan<- models.EnrichmentJob) {
+	for ctx.Err() == nil {
+		claimed, err := s.Repo.ClaimEnrichment(ctx, cfg.BatchSize, cfg.Lease)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.log.Error("can't claim songs to enrich", slog.Any("error", err))
+			}
+			return
+		}
+
+		for _, job := range claimed {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if len(claimed) < cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// enrich fetches the details of the claimed song and records the outcome
+func (s *Service) enrich(ctx context.Context, cfg EnrichmentConfig, job models.EnrichmentJob) {
+	log := s.log.With(slog.Int("song_id", job.ID), slog.Int("attempt", job.Attempts+1))
+
+	// complete songs left pending by an earlier configuration
+	if s.SkipComplete && job.Complete {
+		log.Debug("song is complete, enrichment skipped")
+		s.releaseEnrichment(ctx, log, job, models.Enrichment{Status: models.EnrichmentSkipped, Attempts: job.Attempts})
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, cfg.Lease-cfg.Lease/leaseMargin)
+	defer cancel()
+
+	details, err := s.client.GetDetails(jobCtx, job.Song, job.GroupName)
+	if ctx.Err() != nil {
+		// shutting down, the song is claimed again once its lease runs out
+		return
+	}
+
+	state := models.Enrichment{Status: models.EnrichmentDone, Attempts: job.Attempts + 1}
+	switch {
+	case err == nil:
+		err = s.applyDetails(ctx, job, details, cfg.Merge, state)
+		if err == nil {
+			log.Info("song enriched")
+			return
+		}
+		if errors.Is(err, models.ErrNotFound) {
+			log.Debug("enriched song was deleted")
+			return
+		}
+		if errors.Is(err, models.ErrConflict) {
+			log.Warn("enrichment claim ran out, details dropped", slog.Any("error", err))
+			return
+		}
+		log.Error("can't store song details", slog.Any("error", err))
+		state = s.retryState(cfg, state, err)
+
+	case errors.Is(err, client.ErrDisabled):
+		state = models.Enrichment{Status: models.EnrichmentSkipped, Attempts: job.Attempts}
+
+	case errors.Is(err, client.ErrCircuitOpen):
+		// the request was never sent, so it doesn't count as an attempt
+		retryAt := time.Now().Add(cfg.Backoff)
+		if status := s.client.Status(); status.RetryAt != nil {
+			retryAt = *status.RetryAt
+		}
+		state = models.Enrichment{Status: models.EnrichmentPending, Attempts: job.Attempts, LastError: err.Error(), NextAttemptAt: &retryAt}
+
+	case !client.Temporary(err):
+		log.Warn("song can't be enriched", slog.Any("error", err))
+		state.Status = models.EnrichmentFailed
+		state.LastError = err.Error()
+
+	default:
+		log.Warn("enrichment attempt failed", slog.Any("error", err))
+		state = s.retryState(cfg, state, err)
+	}
+
+	s.releaseEnrichment(ctx, log, job, state)
+}
+
+// releaseEnrichment stores the outcome of the job while it still holds its
+// claim
+func (s *Service) releaseEnrichment(ctx context.Context, log *slog.Logger, job models.EnrichmentJob, state models.Enrichment) {
+	err := s.Repo.ReleaseEnrichment(ctx, job.ID, job.ClaimedUntil, state)
+	switch {
+	case err == nil, errors.Is(err, models.ErrNotFound):
+	case errors.Is(err, models.ErrConflict):
+		log.Warn("enrichment claim ran out, outcome dropped", slog.Any("error", err))
+	default:
+		log.Error("can't store enrichment state", slog.Any("error", err))
+	}
+}
+
+// retryState schedules another attempt after the failed one, unless it was
+// the last
+func (s *Service) retryState(cfg EnrichmentConfig, state models.Enrichment, err error) models.Enrichment {
+	state.LastError = err.Error()
+	if state.Attempts >= cfg.MaxAttempts {
+		state.Status = models.EnrichmentFailed
+		return state
+	}
+
+	next := time.Now().Add(enrichmentBackoff(cfg, state.Attempts))
+	state.Status = models.EnrichmentPending
+	state.NextAttemptAt = &next
+
+	return state
+}
+
+// enrichmentBackoff returns the jittered delay after the given number of
+// failed attempts
+func enrichmentBackoff(cfg EnrichmentConfig, attempts int) time.Duration {
+	d := cfg.Backoff << min(attempts-1, 30)
+	if cfg.MaxBackoff > 0 && (d > cfg.MaxBackoff || d <= 0) {
+		d = cfg.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// applyDetails merges the details into the song as the merge policy says
+// and marks it as enriched. Nothing is stored once the claim of the job ran
+// out.
+func (s *Service) applyDetails(ctx context.Context, job models.EnrichmentJob, details models.Song, merge string, state models.Enrichment) error {
+	id := job.ID
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		song, err := s.Repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		diff, patch, err := detailsPatch(song, details, mergeApplies(song, merge))
+		if err != nil {
+			return err
+		}
+		if !patch.IsEmpty() {
+			if _, err := s.Repo.Patch(ctx, id, patch); err != nil {
+				return err
+			}
+		}
+
+		state.Sources = appliedSources(diff)
+		return s.Repo.ReleaseEnrichment(ctx, id, job.ClaimedUntil, state)
+	})
+}
+
+// Enrichment returns the state of the background enrichment of the song
+func (s *Service) Enrichment(ctx context.Context, id int) (models.Enrichment, error) {
+	return s.Repo.GetEnrichment(ctx, id)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/Fyefhqdishka/eff-mobile/internal/storage/storageInterfaces"
+	"log/slog"
+	"strings"
+)
+
+type GroupServiceInterface interface {
+	Create(ctx context.Context, name string) (models.Group, error)
+	Rename(ctx context.Context, id int, rename models.GroupRename) (models.Group, error)
+	Delete(ctx context.Context, id int) (int, error)
+	GetByID(ctx context.Context, id int) (models.Group, error)
+	Get(ctx context.Context, limit, offset int) ([]models.Group, error)
+	Songs(ctx context.Context, id, limit, offset int) (models.SongPage, error)
+}
+
+type GroupService struct {
+	Repo  storageInterfaces.GroupStorage
+	songs storageInterfaces.Storage
+	tx    storageInterfaces.Transactor
+	log   *slog.Logger
+}
+
+func NewGroupService(repo storageInterfaces.GroupStorage, songs storageInterfaces.Storage, tx storageInterfaces.Transactor, log *slog.Logger) *GroupService {
+	return &GroupService{
+		Repo:  repo,
+		songs: songs,
+		tx:    tx,
+		log:   log,
+	}
+}
+
+func (s *GroupService) Create(ctx context.Context, name string) (models.Group, error) {
+	name, err := validateGroupName(name)
+	if err != nil {
+		return models.Group{}, err
+	}
+
+	return s.Repo.Create(ctx, name)
+}
+
+// Rename changes the group name for all of its songs. When another group
+// already has the name, the rename fails with a conflict unless OnConflict is
+// merge, in which case the songs are moved into that group and the renamed
+// group is removed.
+func (s *GroupService) Rename(ctx context.Context, id int, rename models.GroupRename) (models.Group, error) {
+	name, err := validateGroupName(rename.Name)
+	if err != nil {
+		return models.Group{}, err
+	}
+
+	switch rename.OnConflict {
+	case "", models.OnConflictError, models.OnConflictMerge:
+	default:
+		return models.Group{}, fmt.Errorf("%w: on_conflict must be %q or %q", models.ErrValidation, models.OnConflictError, models.OnConflictMerge)
+	}
+
+	var group models.Group
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		target, err := s.Repo.GetByName(ctx, name)
+		switch {
+		case errors.Is(err, models.ErrNotFound), err == nil && target.ID == id:
+			group, err = s.Repo.Rename(ctx, id, name)
+			return err
+		case err != nil:
+			return err
+		case rename.OnConflict != models.OnConflictMerge:
+			return fmt.Errorf("group %q already exists: %w", name, models.ErrConflict)
+		}
+
+		s.log.Info("merging groups on rename", slog.Int("from_id", id), slog.Int("into_id", target.ID))
+
+		group, err = s.Repo.Merge(ctx, id, target.ID)
+		return err
+	})
+	if err != nil {
+		return models.Group{}, err
+	}
+
+	return group, nil
+}
+
+// Delete removes the group and all of its songs
+func (s *GroupService) Delete(ctx context.Context, id int) (int, error) {
+	return s.Repo.Delete(ctx, id)
+}
+
+func (s *GroupService) GetByID(ctx context.Context, id int) (models.Group, error) {
+	return s.Repo.GetByID(ctx, id)
+}
+
+func (s *GroupService) Get(ctx context.Context, limit, offset int) ([]models.Group, error) {
+	return s.Repo.Get(ctx, limit, offset)
+}
+
+// Songs returns the songs of the group, an unknown group is reported as not found
+func (s *GroupService) Songs(ctx context.Context, id, limit, offset int) (models.SongPage, error) {
+	if _, err := s.Repo.GetByID(ctx, id); err != nil {
+		return models.SongPage{}, err
+	}
+
+	return s.songs.Get(ctx, models.SongFilter{GroupID: id, Limit: limit, Offset: offset})
+}
+
+func validateGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", models.ErrValidation)
+	}
+
+	return name, nil
+}
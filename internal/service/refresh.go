@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/client"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"golang.org/x/sync/errgroup"
+	"log/slog"
+)
+
+// refreshConcurrency bounds the upstream requests of a group refresh
+const refreshConcurrency = 4
+
+// Refresh asks the upstream about the song again, bypassing the cache, and
+// applies its details as apply says. The text of a ChordPro song is only
+// replaced when overwriteChordPro is set.
+func (s *Service) Refresh(ctx context.Context, id int, apply string, overwriteChordPro bool) (models.SongRefresh, error) {
+	apply, err := refreshApply(apply)
+	if err != nil {
+		return models.SongRefresh{}, err
+	}
+
+	song, err := s.Repo.GetByID(ctx, id)
+	if err != nil {
+		return models.SongRefresh{}, err
+	}
+
+	return s.refresh(ctx, song, apply, overwriteChordPro)
+}
+
+// RefreshGroup refreshes a page of the songs of the group. A song that fails
+// on its own, because the upstream can't tell about it, it was deleted
+// meanwhile or its details are invalid, is reported with its error and the
+// others are refreshed anyway. Only storage failures and cancellation fail the
+// whole page.
+func (s *Service) RefreshGroup(ctx context.Context, groupID int, apply string, overwriteChordPro bool, limit, offset int) (models.RefreshPage, error) {
+	apply, err := refreshApply(apply)
+	if err != nil {
+		return models.RefreshPage{}, err
+	}
+
+	if _, err := s.groups.GetByID(ctx, groupID); err != nil {
+		return models.RefreshPage{}, err
+	}
+
+	page, err := s.Repo.Get(ctx, models.SongFilter{GroupID: groupID, Limit: limit, Offset: offset})
+	if err != nil {
+		return models.RefreshPage{}, err
+	}
+
+	refreshes := make([]models.SongRefresh, len(page.Songs))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(refreshConcurrency)
+	for i, song := range page.Songs {
+		g.Go(func() error {
+			res, err := s.refresh(gctx, song, apply, overwriteChordPro)
+			if err != nil && gctx.Err() == nil && songError(err) {
+				res = models.SongRefresh{ID: song.ID, Song: song.Song, GroupName: song.GroupName, Apply: apply, Diff: []models.FieldDiff{}, Error: err.Error()}
+				err = nil
+			}
+			refreshes[i] = res
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return models.RefreshPage{}, ctx.Err()
+		}
+		return models.RefreshPage{}, err
+	}
+
+	return models.RefreshPage{Refreshes: refreshes, Total: page.Total, HasNext: page.HasNext}, nil
+}
+
+// songError tells whether err concerns a single song of a group refresh
+func songError(err error) bool {
+	for _, target := range []error{models.ErrUpstream, models.ErrNotFound, models.ErrValidation, models.ErrConflict} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refresh compares the song with its upstream details and applies them. The
+// stored song is read again in the transaction patching it, so that changes
+// made while the upstream was asked are compared and not overwritten blindly.
+func (s *Service) refresh(ctx context.Context, song models.Song, apply string, overwriteChordPro bool) (models.SongRefresh, error) {
+	details, err := s.client.GetDetails(client.WithoutCache(ctx), song.Song, song.GroupName)
+	if err != nil {
+		return models.SongRefresh{}, fmt.Errorf("%w: %w", models.ErrUpstream, err)
+	}
+
+	res := models.SongRefresh{ID: song.ID, Song: song.Song, GroupName: song.GroupName, Apply: apply}
+	if apply == models.RefreshApplyNone {
+		res.Diff, _, err = detailsPatch(song, details, refreshApplies(song, apply, overwriteChordPro))
+		if err != nil {
+			return models.SongRefresh{}, err
+		}
+		return res, nil
+	}
+
+	// applied details count as an enrichment, a pending one has nothing left
+	// to do
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		song, err := s.Repo.GetByID(ctx, song.ID)
+		if err != nil {
+			return err
+		}
+
+		diff, patch, err := detailsPatch(song, details, refreshApplies(song, apply, overwriteChordPro))
+		if err != nil {
+			return err
+		}
+		if !patch.IsEmpty() {
+			if _, err := s.Repo.Patch(ctx, song.ID, patch); err != nil {
+				return err
+			}
+		}
+
+		state, err := s.Repo.GetEnrichment(ctx, song.ID)
+		if err != nil {
+			return err
+		}
+
+		res.Song, res.GroupName, res.Diff = song.Song, song.GroupName, diff
+		return s.Repo.SetEnrichment(ctx, song.ID, models.Enrichment{Status: models.EnrichmentDone, Attempts: state.Attempts + 1, Sources: appliedSources(diff)})
+	})
+	if err != nil {
+		return models.SongRefresh{}, err
+	}
+
+	s.log.Info("song refreshed", slog.Int("song_id", song.ID), slog.String("apply", apply), slog.Int("changes", len(res.Diff)))
+
+	return res, nil
+}
+
+// applies tells whether an upstream value replaces the stored value of field
+type applies func(field, stored string) bool
+
+// refreshApplies returns what a refresh of song applies
+func refreshApplies(song models.Song, apply string, overwriteChordPro bool) applies {
+	var fn applies
+	switch apply {
+	case models.RefreshApplyAll:
+		fn = func(string, string) bool { return true }
+	case models.RefreshApplyMissing:
+		fn = func(_, stored string) bool { return stored == "" }
+	default:
+		return func(string, string) bool { return false }
+	}
+	if overwriteChordPro {
+		return fn
+	}
+
+	return keepChordPro(song, fn)
+}
+
+// mergeApplies returns what the enrichment of song applies under policy
+func mergeApplies(song models.Song, policy string) applies {
+	switch policy {
+	case models.MergeUpstreamWins:
+		return keepChordPro(song, func(string, string) bool { return true })
+	case models.MergeFillMissing:
+		return func(_, stored string) bool { return stored == "" }
+	}
+
+	return keepChordPro(song, func(field, _ string) bool { return !song.UserFields.Has(field) })
+}
+
+// keepChordPro makes apply leave the text of a ChordPro song alone: the
+// upstream lyrics are plain and replacing the text would lose the chords
+func keepChordPro(song models.Song, apply applies) applies {
+	if song.TextFormat != models.TextFormatChordPro {
+		return apply
+	}
+
+	return func(field, stored string) bool {
+		return (field != models.FieldText || stored == "") && apply(field, stored)
+	}
+}
+
+// detailsPatch compares the song with the upstream details and builds the
+// patch applying the differing values apply lets through. Values the upstream
+// doesn't have are never applied.
+func detailsPatch(song, details models.Song, apply applies) ([]models.FieldDiff, models.SongPatch, error) {
+	var (
+		diff  = []models.FieldDiff{}
+		patch models.SongPatch
+	)
+	field := func(name, stored, upstream string) bool {
+		if upstream == "" || upstream == stored {
+			return false
+		}
+
+		applied := apply(name, stored)
+		diff = append(diff, models.FieldDiff{Field: name, Stored: stored, Upstream: upstream, Applied: applied, Source: details.Sources[name]})
+
+		return applied
+	}
+
+	if field(models.FieldText, song.Text, details.Text) {
+		// upstream lyrics are plain text, a ChordPro text is only replaced
+		// when apply was told to
+		format := models.TextFormatPlain
+		sections, err := textSections(details.Text, format)
+		if err != nil {
+			return nil, models.SongPatch{}, err
+		}
+		patch.Text, patch.TextFormat, patch.Sections = &details.Text, &format, &sections
+	}
+	if field(models.FieldLink, song.Link, details.Link) {
+		patch.Link = &details.Link
+	}
+	if field(models.FieldReleaseDate, song.ReleaseDate.In(models.LegacyDateLayout).String(), details.ReleaseDate.In(models.LegacyDateLayout).String()) {
+		patch.ReleaseDate = &details.ReleaseDate
+	}
+
+	return diff, patch, nil
+}
+
+// complete tells whether song has a value for every enrichable field
+func complete(song models.Song) bool {
+	return song.Text != "" && song.Link != "" && !song.ReleaseDate.IsZero()
+}
+
+// userFields returns the enrichable fields song has a value for
+func userFields(song models.Song) models.FieldSet {
+	fields := models.FieldSet{}
+	if song.Text != "" {
+		fields = append(fields, models.FieldText)
+	}
+	if song.Link != "" {
+		fields = append(fields, models.FieldLink)
+	}
+	if !song.ReleaseDate.IsZero() {
+		fields = append(fields, models.FieldReleaseDate)
+	}
+
+	return fields
+}
+
+// appliedSources returns the providers of the applied fields, nil when
+// there are none or the details came from a single provider
+func appliedSources(diff []models.FieldDiff) models.FieldSources {
+	var sources models.FieldSources
+	for _, d := range diff {
+		if !d.Applied || d.Source == "" {
+			continue
+		}
+		if sources == nil {
+			sources = models.FieldSources{}
+		}
+		sources[d.Field] = d.Source
+	}
+
+	return sources
+}
+
+func refreshApply(apply string) (string, error) {
+	switch apply {
+	case "":
+		return models.RefreshApplyNone, nil
+	case models.RefreshApplyNone, models.RefreshApplyMissing, models.RefreshApplyAll:
+		return apply, nil
+	}
+
+	return "", fmt.Errorf("%w: apply must be %q, %q or %q", models.ErrValidation, models.RefreshApplyNone, models.RefreshApplyMissing, models.RefreshApplyAll)
+}
@@ -1,54 +1,132 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/chordpro"
 	"github.com/Fyefhqdishka/eff-mobile/internal/client"
+	"github.com/Fyefhqdishka/eff-mobile/internal/lyrics"
 	"github.com/Fyefhqdishka/eff-mobile/internal/models"
 	"github.com/Fyefhqdishka/eff-mobile/internal/storage/storageInterfaces"
+	"io"
 	"log/slog"
 	"strings"
+	"time"
 )
 
 type ServiceInterface interface {
-	Create(song models.Song) (models.Song, error)
-	Update(song models.Song) (bool, error)
-	Delete(song models.Song) (int, error)
-	Get(groupName, songName, releaseDate string, limit, offset, songID int) ([]models.Song, error)
-	GetVerses(groupName, songName, releaseDate string, limit, offset, songID int) ([]string, error)
+	Create(ctx context.Context, song models.Song) (models.Song, error)
+	Update(ctx context.Context, song models.Song) (bool, error)
+	Patch(ctx context.Context, id int, patch models.SongPatch) (models.Song, error)
+	Delete(ctx context.Context, id int) (int, error)
+	GetByID(ctx context.Context, id int) (models.Song, error)
+	Get(ctx context.Context, filter models.SongFilter) (models.SongPage, error)
+	Verses(ctx context.Context, id int, types []string, limit, offset int) ([]models.Verse, int, error)
+	Sections(ctx context.Context, id int, expand bool, types []string) (models.Sections, error)
+	Chords(ctx context.Context, id int, transpose int, notation string) (models.ChordSheet, error)
+	Search(ctx context.Context, query models.SearchQuery) ([]models.SongSearchResult, error)
+	ImportLRC(ctx context.Context, id int, r io.Reader) (models.TimedLyrics, error)
+	TimedLyrics(ctx context.Context, id int) (models.TimedLyrics, error)
+	LyricsAt(ctx context.Context, id int, t time.Duration) (models.LyricsPosition, error)
+	UpstreamStatus() models.UpstreamStatus
+	Enrichment(ctx context.Context, id int) (models.Enrichment, error)
+	Refresh(ctx context.Context, id int, apply string, overwriteChordPro bool) (models.SongRefresh, error)
+	RefreshGroup(ctx context.Context, groupID int, apply string, overwriteChordPro bool, limit, offset int) (models.RefreshPage, error)
 }
 
 type Service struct {
 	Repo   storageInterfaces.Storage
+	groups storageInterfaces.GroupStorage
+	tx     storageInterfaces.Transactor
 	client client.ClientInterface
 	log    *slog.Logger
+	// SkipComplete stores songs created with a text, a link and a release
+	// date as skipped instead of enriching them
+	SkipComplete bool
+	// wake tells the enrichment pool about a new pending song
+	wake chan struct{}
 }
 
-func NewService(repo storageInterfaces.Storage, client client.ClientInterface, log *slog.Logger) *Service {
+func NewService(repo storageInterfaces.Storage, groups storageInterfaces.GroupStorage, tx storageInterfaces.Transactor, client client.ClientInterface, log *slog.Logger) *Service {
 	return &Service{
 		Repo:   repo,
+		groups: groups,
+		tx:     tx,
 		client: client,
 		log:    log,
+		wake:   make(chan struct{}, 1),
 	}
 }
 
-func (s *Service) Create(song models.Song) (models.Song, error) {
-	res, err := s.client.GetDetails(song.Song, song.GroupName)
+func (s *Service) Create(ctx context.Context, song models.Song) (models.Song, error) {
+	song.TextFormat = textFormat(song.TextFormat)
+	if err := validateSong(song); err != nil {
+		return models.Song{}, err
+	}
+
+	sections, err := textSections(song.Text, song.TextFormat)
 	if err != nil {
 		return models.Song{}, err
 	}
+	song.Sections = sections
+	song.UserFields = userFields(song)
+
+	// the song is stored right away and enriched in the background, with
+	// enrichment turned off or nothing to enrich it is stored as sent
+	song.Enrichment = &models.Enrichment{Status: models.EnrichmentPending}
+	if s.client.Status().State == client.UpstreamDisabled || s.SkipComplete && complete(song) {
+		song.Enrichment.Status = models.EnrichmentSkipped
+	}
+
+	var id int
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		group, err := s.groups.Ensure(ctx, song.GroupName)
+		if err != nil {
+			return err
+		}
+		song.GroupID = group.ID
 
-	id, err := s.Repo.Create(song)
+		id, err = s.Repo.Create(ctx, song)
+		return err
+	})
 	if err != nil {
 		return models.Song{}, err
 	}
+	song.ID = id
 
-	res.ID = id
+	if song.Enrichment.Status == models.EnrichmentPending {
+		s.wakeEnrichment()
+	}
 
-	return res, nil
+	return song, nil
 }
 
-func (s *Service) Update(song models.Song) (bool, error) {
-	success, err := s.Repo.Update(song)
+func (s *Service) Update(ctx context.Context, song models.Song) (bool, error) {
+	song.TextFormat = textFormat(song.TextFormat)
+	if err := validateSong(song); err != nil {
+		return false, err
+	}
+
+	sections, err := textSections(song.Text, song.TextFormat)
+	if err != nil {
+		return false, err
+	}
+	song.Sections = sections
+	song.UserFields = userFields(song)
+
+	// the song is moved to the group with the given name, other songs of its
+	// previous group are left alone
+	var success bool
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		group, err := s.groups.Ensure(ctx, song.GroupName)
+		if err != nil {
+			return err
+		}
+		song.GroupID = group.ID
+
+		success, err = s.Repo.Update(ctx, song)
+		return err
+	})
 	if err != nil {
 		return false, err
 	}
@@ -56,8 +134,100 @@ func (s *Service) Update(song models.Song) (bool, error) {
 	return success, nil
 }
 
-func (s *Service) Delete(song models.Song) (int, error) {
-	id, err := s.Repo.Delete(song)
+// Patch updates only the fields set in patch and returns the resulting song
+func (s *Service) Patch(ctx context.Context, id int, patch models.SongPatch) (models.Song, error) {
+	if patch.IsEmpty() {
+		return models.Song{}, fmt.Errorf("%w: patch changes nothing", models.ErrValidation)
+	}
+	if patch.Song != nil && strings.TrimSpace(*patch.Song) == "" {
+		return models.Song{}, fmt.Errorf("%w: song can't be empty", models.ErrValidation)
+	}
+	if patch.GroupName != nil && strings.TrimSpace(*patch.GroupName) == "" {
+		return models.Song{}, fmt.Errorf("%w: group_name can't be empty", models.ErrValidation)
+	}
+
+	if patch.TextFormat != nil {
+		format := textFormat(*patch.TextFormat)
+		if err := validateTextFormat(format); err != nil {
+			return models.Song{}, err
+		}
+		patch.TextFormat = &format
+	}
+
+	// a field the user cleared stays theirs, enrichment won't refill it
+	// unless the merge policy ignores the user
+	patch.UserFields = patchedFields(patch)
+
+	var song models.Song
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if patch.Text != nil || patch.TextFormat != nil {
+			if err := s.patchSections(ctx, id, &patch); err != nil {
+				return err
+			}
+		}
+		if patch.GroupName != nil {
+			group, err := s.groups.Ensure(ctx, *patch.GroupName)
+			if err != nil {
+				return err
+			}
+			patch.GroupID = &group.ID
+		}
+
+		var err error
+		song, err = s.Repo.Patch(ctx, id, patch)
+		return err
+	})
+	if err != nil {
+		return models.Song{}, err
+	}
+
+	return song, nil
+}
+
+// patchedFields returns the enrichable fields the patch sets, nil if none
+func patchedFields(patch models.SongPatch) models.FieldSet {
+	var fields models.FieldSet
+	if patch.Text != nil {
+		fields = append(fields, models.FieldText)
+	}
+	if patch.Link != nil {
+		fields = append(fields, models.FieldLink)
+	}
+	if patch.ReleaseDate != nil {
+		fields = append(fields, models.FieldReleaseDate)
+	}
+
+	return fields
+}
+
+// patchSections parses the sections of the patched text. The stored song
+// fills in the text or its format when the patch changes only one of them.
+func (s *Service) patchSections(ctx context.Context, id int, patch *models.SongPatch) error {
+	text, format := patch.Text, patch.TextFormat
+	if text == nil || format == nil {
+		song, err := s.Repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if text == nil {
+			text = &song.Text
+		}
+		if format == nil {
+			format = &song.TextFormat
+		}
+	}
+
+	sections, err := textSections(*text, *format)
+	if err != nil {
+		return err
+	}
+	patch.Sections = &sections
+
+	return nil
+}
+
+func (s *Service) Delete(ctx context.Context, id int) (int, error) {
+	id, err := s.Repo.Delete(ctx, id)
 	if err != nil {
 		return 0, err
 	}
@@ -65,40 +235,158 @@ func (s *Service) Delete(song models.Song) (int, error) {
 	return id, nil
 }
 
-func (s *Service) Get(groupName, songName, releaseDate string, limit, offset, songID int) ([]models.Song, error) {
-	return s.Repo.Get(groupName, songName, releaseDate, limit, offset, songID)
+func (s *Service) GetByID(ctx context.Context, id int) (models.Song, error) {
+	return s.Repo.GetByID(ctx, id)
 }
 
-func (s *Service) GetVerses(groupName, songName, releaseDate string, limit, offset, songID int) ([]string, error) {
-	s.log.Debug("Start fetching verses", "groupName", groupName, "songName", songName, "releaseDate", releaseDate, "songID", songID)
+func (s *Service) Get(ctx context.Context, filter models.SongFilter) (models.SongPage, error) {
+	if err := validateFilter(filter); err != nil {
+		return models.SongPage{}, err
+	}
 
-	songs, err := s.Repo.Get(groupName, songName, releaseDate, limit, offset, songID)
+	return s.Repo.Get(ctx, filter)
+}
+
+// Verses returns a page of the verses of the song of the given section types
+// and the number of such verses. The song is looked up by id alone, limit and
+// offset apply to its verses. Repeated sections are expanded.
+func (s *Service) Verses(ctx context.Context, id int, types []string, limit, offset int) ([]models.Verse, int, error) {
+	s.log.Debug("Start fetching verses", slog.Int("song_id", id), slog.Any("types", types), slog.Int("limit", limit), slog.Int("offset", offset))
+
+	song, err := s.Repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// numbers are positions in the whole song, so they are assigned before
+	// filtering
+	verses := []models.Verse{}
+	for i, section := range lyrics.Expand(songSections(song)) {
+		if !lyrics.Matches(section, types) {
+			continue
+		}
+		verses = append(verses, models.Verse{Number: i + 1, Type: section.Type, Lines: section.Lines})
+	}
+
+	// the first page of a song without lyrics is empty rather than missing
+	if offset > 0 && offset >= len(verses) {
+		return nil, 0, models.ErrPageOutOfRange
+	}
+	end := min(offset+limit, len(verses))
+
+	return verses[offset:end], len(verses), nil
+}
+
+// Sections returns the lyrics of the song split into typed sections, with
+// repeated sections filled in when expand is set
+func (s *Service) Sections(ctx context.Context, id int, expand bool, types []string) (models.Sections, error) {
+	song, err := s.Repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	s.log.Debug("Fetched songs", "songs", songs)
+	sections := songSections(song)
+	if expand {
+		sections = lyrics.Expand(sections)
+	}
+
+	return lyrics.Filter(sections, types), nil
+}
 
-	if len(songs) == 0 {
-		return nil, fmt.Errorf("song not found")
+// UpstreamStatus returns the state of the circuit breaker of the enrichment
+// API client
+func (s *Service) UpstreamStatus() models.UpstreamStatus {
+	return s.client.Status()
+}
+
+// validateSong checks the fields every stored song must have
+func validateSong(song models.Song) error {
+	if strings.TrimSpace(song.Song) == "" {
+		return fmt.Errorf("%w: song is required", models.ErrValidation)
+	}
+	if strings.TrimSpace(song.GroupName) == "" {
+		return fmt.Errorf("%w: group_name is required", models.ErrValidation)
 	}
 
-	song := songs[0]
+	return validateTextFormat(song.TextFormat)
+}
 
-	verses := splitSongTextToVerses(song.Text)
+func validateTextFormat(format string) error {
+	switch format {
+	case models.TextFormatPlain, models.TextFormatChordPro:
+		return nil
+	}
 
-	startIdx := offset * limit
-	endIdx := startIdx + limit
-	if startIdx >= len(verses) {
-		return nil, fmt.Errorf("page out of range")
+	return fmt.Errorf("%w: text_format must be %q or %q", models.ErrValidation, models.TextFormatPlain, models.TextFormatChordPro)
+}
+
+// textFormat defaults an empty format to plain
+func textFormat(format string) string {
+	if format == "" {
+		return models.TextFormatPlain
 	}
-	if endIdx > len(verses) {
-		endIdx = len(verses)
+
+	return format
+}
+
+// textSections parses song text written in format into sections, ChordPro
+// text is stripped of its chords first
+func textSections(text, format string) (models.Sections, error) {
+	if format != models.TextFormatChordPro {
+		return lyrics.Parse(text), nil
+	}
+
+	sheet, err := chordpro.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid chordpro: %w", models.ErrValidation, err)
+	}
+
+	return lyrics.Parse(chordpro.Lyrics(sheet)), nil
+}
+
+// Search finds songs whose title, group name or lyrics match the query
+func (s *Service) Search(ctx context.Context, query models.SearchQuery) ([]models.SongSearchResult, error) {
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return nil, fmt.Errorf("%w: search query is required", models.ErrValidation)
+	}
+
+	switch query.Language {
+	case "", models.SearchLangEnglish, models.SearchLangRussian:
+	default:
+		return nil, fmt.Errorf("%w: lang must be %q or %q", models.ErrValidation, models.SearchLangEnglish, models.SearchLangRussian)
 	}
 
-	return verses[startIdx:endIdx], nil
+	return s.Repo.Search(ctx, query)
 }
 
-func splitSongTextToVerses(text string) []string {
-	return strings.Split(text, "\n")
+// validateFilter rejects filters that can never match
+func validateFilter(filter models.SongFilter) error {
+	if !filter.ReleasedFrom.IsZero() && !filter.ReleasedTo.IsZero() && filter.ReleasedTo.Before(filter.ReleasedFrom) {
+		return fmt.Errorf("%w: released_from is after released_to", models.ErrValidation)
+	}
+	if filter.Year < 0 {
+		return fmt.Errorf("%w: year must be positive", models.ErrValidation)
+	}
+	if filter.Match == models.MatchFuzzy && filter.Song == "" && filter.GroupName == "" {
+		return fmt.Errorf("%w: fuzzy match needs song or group_name", models.ErrValidation)
+	}
+	if filter.After != nil && filter.Offset != 0 {
+		return fmt.Errorf("%w: cursor can't be combined with offset", models.ErrValidation)
+	}
+	if filter.After != nil && filter.Match == models.MatchFuzzy && len(filter.Sort) == 0 {
+		return fmt.Errorf("%w: cursor needs an explicit sort for fuzzy match", models.ErrValidation)
+	}
+
+	return nil
+}
+
+// songSections returns the stored sections of song, songs stored before
+// sections were introduced are parsed on the fly
+func songSections(song models.Song) models.Sections {
+	if song.Sections != nil {
+		return song.Sections
+	}
+
+	return lyrics.Parse(song.Text)
 }
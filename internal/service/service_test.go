@@ -1,79 +1,181 @@
 package service_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/client"
 	"github.com/Fyefhqdishka/eff-mobile/internal/models"
 	"github.com/Fyefhqdishka/eff-mobile/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"io"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type MockRepo struct {
 	mock.Mock
 }
 
-func (m *MockRepo) Create(song models.Song) (int, error) {
-	args := m.Called(song)
+func (m *MockRepo) Create(ctx context.Context, song models.Song) (int, error) {
+	args := m.Called(ctx, song)
 	return args.Int(0), args.Error(1)
 }
 
-func (m *MockRepo) Update(song models.Song) (bool, error) {
-	args := m.Called(song)
+func (m *MockRepo) Update(ctx context.Context, song models.Song) (bool, error) {
+	args := m.Called(ctx, song)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockRepo) Delete(song models.Song) (int, error) {
-	args := m.Called(song)
+func (m *MockRepo) Patch(ctx context.Context, id int, patch models.SongPatch) (models.Song, error) {
+	args := m.Called(ctx, id, patch)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockRepo) Delete(ctx context.Context, id int) (int, error) {
+	args := m.Called(ctx, id)
 	return args.Int(0), args.Error(1)
 }
 
-func (m *MockRepo) Get(groupName, songName, releaseDate string, limit, offset, songID int) ([]models.Song, error) {
-	args := m.Called(groupName, songName, releaseDate, limit, offset, songID)
-	return args.Get(0).([]models.Song), args.Error(1)
+func (m *MockRepo) GetByID(ctx context.Context, id int) (models.Song, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockRepo) Get(ctx context.Context, filter models.SongFilter) (models.SongPage, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(models.SongPage), args.Error(1)
+}
+
+func (m *MockRepo) Search(ctx context.Context, query models.SearchQuery) ([]models.SongSearchResult, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]models.SongSearchResult), args.Error(1)
+}
+
+func (m *MockRepo) SetTimedLyrics(ctx context.Context, id int, lyrics models.TimedLyrics) error {
+	args := m.Called(ctx, id, lyrics)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ClaimEnrichment(ctx context.Context, limit int, lease time.Duration) ([]models.EnrichmentJob, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]models.EnrichmentJob), args.Error(1)
+}
+
+func (m *MockRepo) SetEnrichment(ctx context.Context, id int, state models.Enrichment) error {
+	args := m.Called(ctx, id, state)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ReleaseEnrichment(ctx context.Context, id int, claimedUntil time.Time, state models.Enrichment) error {
+	args := m.Called(ctx, id, claimedUntil, state)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetEnrichment(ctx context.Context, id int) (models.Enrichment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Enrichment), args.Error(1)
+}
+
+// NoopTx runs the unit of work without a database transaction
+type NoopTx struct{}
+
+func (NoopTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
 }
 
 type MockClient struct {
 	mock.Mock
 }
 
-func (m *MockClient) GetDetails(songName, groupName string) (models.Song, error) {
-	args := m.Called(songName, groupName)
+func (m *MockClient) GetDetails(ctx context.Context, songName, groupName string) (models.Song, error) {
+	args := m.Called(ctx, songName, groupName)
 	return args.Get(0).(models.Song), args.Error(1)
 }
 
+func (m *MockClient) Status() models.UpstreamStatus {
+	return m.Called().Get(0).(models.UpstreamStatus)
+}
+
 func TestCreateSong(t *testing.T) {
 	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
 	mockClient := new(MockClient)
 	mockLog := slog.Logger{}
 
-	service := service.NewService(mockRepo, mockClient, &mockLog)
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
 
 	song := models.Song{
 		Song:        "SongName",
 		GroupName:   "GroupName",
 		Text:        "Some song text",
 		Link:        "some-link",
-		ReleaseDate: "2024-01-01",
+		ReleaseDate: models.NewDate(2024, time.January, 1),
 	}
 
-	mockClient.On("GetDetails", song.Song, song.GroupName).Return(song, nil)
-	mockRepo.On("Create", song).Return(1, nil)
+	stored := song
+	stored.GroupID = 3
+	stored.TextFormat = models.TextFormatPlain
+	stored.Sections = models.Sections{{Type: models.SectionVerse, Number: 1, Lines: []string{"Some song text"}}}
+	stored.Enrichment = &models.Enrichment{Status: models.EnrichmentPending}
+	stored.UserFields = models.FieldSet{models.FieldText, models.FieldLink, models.FieldReleaseDate}
+
+	mockClient.On("Status").Return(models.UpstreamStatus{State: client.BreakerClosed})
+	mockGroups.On("Ensure", ctx, song.GroupName).Return(models.Group{ID: 3, Name: song.GroupName}, nil)
+	mockRepo.On("Create", ctx, stored).Return(1, nil)
 
-	createdSong, err := service.Create(song)
+	createdSong, err := service.Create(ctx, song)
 	assert.Nil(t, err)
 	assert.Equal(t, 1, createdSong.ID)
-	mockClient.AssertExpectations(t)
+	assert.Equal(t, models.EnrichmentPending, createdSong.Enrichment.Status)
+	mockRepo.AssertExpectations(t)
+
+	// the upstream is only asked in the background
+	mockClient.AssertNotCalled(t, "GetDetails", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateCompleteSong(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+
+	ctx := context.Background()
+	svc := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	svc.SkipComplete = true
+
+	complete := models.Song{Song: "SongName", GroupName: "GroupName", Text: "text", Link: "some-link", ReleaseDate: models.NewDate(2024, time.January, 1)}
+	incomplete := models.Song{Song: "Other", GroupName: "GroupName", Link: "some-link"}
+
+	mockClient.On("Status").Return(models.UpstreamStatus{State: client.BreakerClosed})
+	mockGroups.On("Ensure", ctx, "GroupName").Return(models.Group{ID: 3, Name: "GroupName"}, nil)
+	mockRepo.On("Create", ctx, mock.MatchedBy(func(song models.Song) bool { return song.Song == "SongName" })).Return(1, nil)
+	mockRepo.On("Create", ctx, mock.MatchedBy(func(song models.Song) bool { return song.Song == "Other" })).Return(2, nil)
+
+	// a song sent with all the fields is never enriched
+	created, err := svc.Create(ctx, complete)
+	assert.Nil(t, err)
+	assert.Equal(t, models.EnrichmentSkipped, created.Enrichment.Status)
+
+	created, err = svc.Create(ctx, incomplete)
+	assert.Nil(t, err)
+	assert.Equal(t, models.EnrichmentPending, created.Enrichment.Status)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestUpdateSong(t *testing.T) {
 	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
 	mockClient := new(MockClient)
 	mockLog := slog.Logger{}
 
-	service := service.NewService(mockRepo, mockClient, &mockLog)
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
 
 	song := models.Song{
 		ID:          1,
@@ -81,12 +183,19 @@ func TestUpdateSong(t *testing.T) {
 		GroupName:   "GroupName",
 		Text:        "Some song text",
 		Link:        "some-link",
-		ReleaseDate: "2024-01-01",
+		ReleaseDate: models.NewDate(2024, time.January, 1),
 	}
 
-	mockRepo.On("Update", song).Return(true, nil)
+	stored := song
+	stored.GroupID = 3
+	stored.TextFormat = models.TextFormatPlain
+	stored.Sections = models.Sections{{Type: models.SectionVerse, Number: 1, Lines: []string{"Some song text"}}}
+	stored.UserFields = models.FieldSet{models.FieldText, models.FieldLink, models.FieldReleaseDate}
 
-	updated, err := service.Update(song)
+	mockGroups.On("Ensure", ctx, song.GroupName).Return(models.Group{ID: 3, Name: song.GroupName}, nil)
+	mockRepo.On("Update", ctx, stored).Return(true, nil)
+
+	updated, err := service.Update(ctx, song)
 	assert.Nil(t, err)
 	assert.True(t, updated)
 	mockRepo.AssertExpectations(t)
@@ -94,10 +203,12 @@ func TestUpdateSong(t *testing.T) {
 
 func TestDeleteSong(t *testing.T) {
 	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
 	mockClient := new(MockClient)
 	mockLog := slog.Logger{}
 
-	service := service.NewService(mockRepo, mockClient, &mockLog)
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
 
 	song := models.Song{
 		ID:          1,
@@ -105,12 +216,12 @@ func TestDeleteSong(t *testing.T) {
 		GroupName:   "GroupName",
 		Text:        "Some song text",
 		Link:        "some-link",
-		ReleaseDate: "2024-01-01",
+		ReleaseDate: models.NewDate(2024, time.January, 1),
 	}
 
-	mockRepo.On("Delete", song).Return(1, nil)
+	mockRepo.On("Delete", ctx, song.ID).Return(1, nil)
 
-	deletedID, err := service.Delete(song)
+	deletedID, err := service.Delete(ctx, song.ID)
 	assert.Nil(t, err)
 	assert.Equal(t, 1, deletedID)
 	mockRepo.AssertExpectations(t)
@@ -118,10 +229,12 @@ func TestDeleteSong(t *testing.T) {
 
 func TestGetSongs(t *testing.T) {
 	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
 	mockClient := new(MockClient)
 	mockLog := slog.Logger{}
 
-	service := service.NewService(mockRepo, mockClient, &mockLog)
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
 
 	song := models.Song{
 		ID:          1,
@@ -129,19 +242,27 @@ func TestGetSongs(t *testing.T) {
 		GroupName:   "GroupName",
 		Text:        "Some song text",
 		Link:        "some-link",
-		ReleaseDate: "2024-01-01",
+		ReleaseDate: models.NewDate(2024, time.January, 1),
 	}
 
-	mockRepo.On("Get", "GroupName", "SongName", "2024-01-01", 10, 0, 0).Return([]models.Song{song}, nil)
+	filter := models.SongFilter{
+		GroupName:   "GroupName",
+		Song:        "SongName",
+		ReleaseDate: models.NewDate(2024, time.January, 1),
+		Limit:       10,
+	}
+	mockRepo.On("Get", ctx, filter).Return(models.SongPage{Songs: []models.Song{song}, Total: 1}, nil)
 
-	songs, err := service.Get("GroupName", "SongName", "2024-01-01", 10, 0, 0)
+	page, err := service.Get(ctx, filter)
 	assert.Nil(t, err)
-	assert.Len(t, songs, 1)
+	assert.Len(t, page.Songs, 1)
+	assert.Equal(t, 1, page.Total)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestGetVerses(t *testing.T) {
+func TestVerses(t *testing.T) {
 	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
 	mockClient := new(MockClient)
 	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
@@ -149,21 +270,731 @@ func TestGetVerses(t *testing.T) {
 
 	mockLog := slog.New(handler)
 
-	service := service.NewService(mockRepo, mockClient, mockLog)
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, mockLog)
 
 	song := models.Song{
-		ID:          1,
-		Song:        "SongName",
-		GroupName:   "GroupName",
-		Text:        "Verse 1\nVerse 2\nVerse 3",
-		Link:        "some-link",
-		ReleaseDate: "2024-01-01",
+		ID:   1,
+		Song: "SongName",
+		Text: "first verse\nstill first\n\n\nsecond verse  \r\n\r\nthird verse\n\nfourth verse\n\n",
 	}
+	mockRepo.On("GetByID", ctx, 1).Return(song, nil)
 
-	mockRepo.On("Get", "GroupName", "SongName", "2024-01-01", 1, 0, 0).Return([]models.Song{song}, nil)
+	verses, total, err := service.Verses(ctx, 1, nil, 2, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []models.Verse{
+		{Number: 1, Type: models.SectionVerse, Lines: []string{"first verse", "still first"}},
+		{Number: 2, Type: models.SectionVerse, Lines: []string{"second verse"}},
+	}, verses)
 
-	verses, err := service.GetVerses("GroupName", "SongName", "2024-01-01", 1, 0, 0)
+	verses, _, err = service.Verses(ctx, 1, nil, 2, 2)
 	assert.Nil(t, err)
-	assert.Equal(t, []string{"Verse 1"}, verses)
+	assert.Equal(t, []models.Verse{
+		{Number: 3, Type: models.SectionVerse, Lines: []string{"third verse"}},
+		{Number: 4, Type: models.SectionVerse, Lines: []string{"fourth verse"}},
+	}, verses)
+
+	_, _, err = service.Verses(ctx, 1, nil, 2, 4)
+	assert.ErrorIs(t, err, models.ErrPageOutOfRange)
+	mockRepo.AssertExpectations(t)
+}
+
+// claimedUntil is when the claim of the jobs run by runEnrichment runs out
+var claimedUntil = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// runEnrichment starts the enrichment pool, which claims jobs once, and stops
+// it after n songs had their state stored
+func runEnrichment(t *testing.T, svc *service.Service, mockRepo *MockRepo, merge string, jobs []models.EnrichmentJob, n int) {
+	for i := range jobs {
+		jobs[i].ClaimedUntil = claimedUntil
+	}
+	cfg := service.EnrichmentConfig{
+		Workers:      2,
+		BatchSize:    10,
+		PollInterval: time.Hour,
+		Lease:        time.Minute,
+		MaxAttempts:  3,
+		Backoff:      time.Second,
+		MaxBackoff:   time.Minute,
+		Merge:        merge,
+	}
+
+	stored := make(chan struct{}, n)
+	mockRepo.On("ClaimEnrichment", mock.Anything, cfg.BatchSize, cfg.Lease).Return(jobs, nil).Once()
+	for _, call := range mockRepo.ExpectedCalls {
+		if call.Method == "ReleaseEnrichment" {
+			call.Run(func(mock.Arguments) { stored <- struct{}{} })
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wait := svc.StartEnrichment(ctx, cfg)
+	defer func() {
+		cancel()
+		wait()
+	}()
+
+	for range n {
+		select {
+		case <-stored:
+		case <-time.After(5 * time.Second):
+			t.Fatal("enrichment state not stored")
+		}
+	}
+}
+
+func TestEnrichmentMergePolicies(t *testing.T) {
+	details := models.Song{
+		Text:        "first verse",
+		Link:        "upstream-link",
+		ReleaseDate: models.NewDate(2024, time.January, 1),
+		Sources:     models.FieldSources{"text": "primary", "link": "backup", "releasedate": "primary"},
+	}
+	// the link was set by the user, the release date by an earlier refresh
+	stored := models.Song{
+		ID: 1, Song: "SongName", GroupName: "GroupName", TextFormat: models.TextFormatChordPro,
+		Link: "user-link", ReleaseDate: models.NewDate(2023, time.January, 1), UserFields: models.FieldSet{models.FieldLink},
+	}
+
+	text, format := details.Text, models.TextFormatPlain
+	sections := models.Sections{{Type: models.SectionVerse, Number: 1, Lines: []string{"first verse"}}}
+
+	for _, tc := range []struct {
+		merge   string
+		patch   models.SongPatch
+		sources models.FieldSources
+	}{
+		{
+			merge:   models.MergeUserWins,
+			patch:   models.SongPatch{Text: &text, TextFormat: &format, Sections: &sections, ReleaseDate: &details.ReleaseDate},
+			sources: models.FieldSources{"text": "primary", "releasedate": "primary"},
+		},
+		{
+			merge:   models.MergeFillMissing,
+			patch:   models.SongPatch{Text: &text, TextFormat: &format, Sections: &sections},
+			sources: models.FieldSources{"text": "primary"},
+		},
+		{
+			merge:   models.MergeUpstreamWins,
+			patch:   models.SongPatch{Text: &text, TextFormat: &format, Sections: &sections, Link: &details.Link, ReleaseDate: &details.ReleaseDate},
+			sources: models.FieldSources{"text": "primary", "link": "backup", "releasedate": "primary"},
+		},
+	} {
+		t.Run(tc.merge, func(t *testing.T) {
+			mockRepo := new(MockRepo)
+			mockClient := new(MockClient)
+			svc := service.NewService(mockRepo, nil, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			mockClient.On("GetDetails", mock.Anything, "SongName", "GroupName").Return(details, nil)
+			mockRepo.On("GetByID", mock.Anything, 1).Return(stored, nil)
+			mockRepo.On("Patch", mock.Anything, 1, tc.patch).Return(stored, nil)
+			// only the applied fields record their source
+			mockRepo.On("ReleaseEnrichment", mock.Anything, 1, claimedUntil, models.Enrichment{Status: models.EnrichmentDone, Attempts: 1, Sources: tc.sources}).Return(nil)
+
+			runEnrichment(t, svc, mockRepo, tc.merge, []models.EnrichmentJob{{ID: 1, Song: "SongName", GroupName: "GroupName"}}, 1)
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEnrichmentSkipsCompleteSongs(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockClient := new(MockClient)
+	svc := service.NewService(mockRepo, nil, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	svc.SkipComplete = true
+
+	mockRepo.On("ReleaseEnrichment", mock.Anything, 1, claimedUntil, models.Enrichment{Status: models.EnrichmentSkipped}).Return(nil)
+
+	runEnrichment(t, svc, mockRepo, models.MergeUserWins, []models.EnrichmentJob{{ID: 1, Song: "SongName", GroupName: "GroupName", Complete: true}}, 1)
+
 	mockRepo.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "GetDetails", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEnrichmentFailures(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockClient := new(MockClient)
+	svc := service.NewService(mockRepo, nil, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	refused := errors.New("connection refused")
+	mockClient.On("GetDetails", mock.Anything, "Retried", "GroupName").Return(models.Song{}, refused)
+	mockClient.On("GetDetails", mock.Anything, "Exhausted", "GroupName").Return(models.Song{}, refused)
+	mockClient.On("GetDetails", mock.Anything, "Unknown", "GroupName").Return(models.Song{}, &client.StatusError{Code: 404, Status: "404 Not Found"})
+
+	// a failed attempt is retried later until the attempts run out
+	mockRepo.On("ReleaseEnrichment", mock.Anything, 1, claimedUntil, mock.MatchedBy(func(state models.Enrichment) bool {
+		return state.Status == models.EnrichmentPending && state.Attempts == 1 &&
+			state.LastError == "connection refused" && state.NextAttemptAt != nil && state.NextAttemptAt.After(time.Now())
+	})).Return(nil)
+	mockRepo.On("ReleaseEnrichment", mock.Anything, 2, claimedUntil, models.Enrichment{Status: models.EnrichmentFailed, Attempts: 3, LastError: "connection refused"}).Return(nil)
+
+	// an answer retrying won't change fails the song right away
+	mockRepo.On("ReleaseEnrichment", mock.Anything, 3, claimedUntil, models.Enrichment{Status: models.EnrichmentFailed, Attempts: 1, LastError: "received non-OK response status: 404 Not Found"}).Return(nil)
+
+	runEnrichment(t, svc, mockRepo, models.MergeUserWins, []models.EnrichmentJob{
+		{ID: 1, Song: "Retried", GroupName: "GroupName"},
+		{ID: 2, Song: "Exhausted", GroupName: "GroupName", Attempts: 2},
+		{ID: 3, Song: "Unknown", GroupName: "GroupName"},
+	}, 3)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEnrichmentLostClaim(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockClient := new(MockClient)
+	svc := service.NewService(mockRepo, nil, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	link := "https://example.com"
+	mockClient.On("GetDetails", mock.Anything, "SongName", "GroupName").Return(models.Song{Link: link}, nil)
+	mockRepo.On("GetByID", mock.Anything, 1).Return(models.Song{ID: 1, Song: "SongName"}, nil)
+	mockRepo.On("Patch", mock.Anything, 1, models.SongPatch{Link: &link}).Return(models.Song{}, nil)
+	// the song was claimed again while the worker waited for the upstream
+	mockRepo.On("ReleaseEnrichment", mock.Anything, 1, claimedUntil, mock.Anything).Return(fmt.Errorf("enrichment claim of song 1 ran out: %w", models.ErrConflict))
+
+	runEnrichment(t, svc, mockRepo, models.MergeUserWins, []models.EnrichmentJob{{ID: 1, Song: "SongName", GroupName: "GroupName"}}, 1)
+
+	// the stale worker neither schedules a retry nor stores anything else
+	mockRepo.AssertNumberOfCalls(t, "ReleaseEnrichment", 1)
+	mockRepo.AssertNotCalled(t, "SetEnrichment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateSongValidation(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
+
+	_, err := service.Create(context.Background(), models.Song{Song: "SongName"})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	mockClient.AssertNotCalled(t, "GetDetails", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchSongValidation(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
+
+	_, err := service.Patch(ctx, 1, models.SongPatch{})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	blank := " "
+	_, err = service.Patch(ctx, 1, models.SongPatch{GroupName: &blank})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+type MockGroupRepo struct {
+	mock.Mock
+}
+
+func (m *MockGroupRepo) Create(ctx context.Context, name string) (models.Group, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupRepo) Ensure(ctx context.Context, name string) (models.Group, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupRepo) Rename(ctx context.Context, id int, name string) (models.Group, error) {
+	args := m.Called(ctx, id, name)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupRepo) Merge(ctx context.Context, fromID, intoID int) (models.Group, error) {
+	args := m.Called(ctx, fromID, intoID)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupRepo) GetByName(ctx context.Context, name string) (models.Group, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupRepo) Delete(ctx context.Context, id int) (int, error) {
+	args := m.Called(ctx, id)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockGroupRepo) GetByID(ctx context.Context, id int) (models.Group, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Group), args.Error(1)
+}
+
+func (m *MockGroupRepo) Get(ctx context.Context, limit, offset int) ([]models.Group, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).([]models.Group), args.Error(1)
+}
+
+func TestCreateGroupTrimsName(t *testing.T) {
+	mockGroups := new(MockGroupRepo)
+	mockRepo := new(MockRepo)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	groups := service.NewGroupService(mockGroups, mockRepo, NoopTx{}, &mockLog)
+
+	mockGroups.On("Create", ctx, "Muse").Return(models.Group{ID: 1, Name: "Muse"}, nil)
+
+	group, err := groups.Create(ctx, "  Muse ")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, group.ID)
+
+	_, err = groups.Create(ctx, " ")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	mockGroups.AssertExpectations(t)
+}
+
+func TestGroupSongsUnknownGroup(t *testing.T) {
+	mockGroups := new(MockGroupRepo)
+	mockRepo := new(MockRepo)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	groups := service.NewGroupService(mockGroups, mockRepo, NoopTx{}, &mockLog)
+
+	mockGroups.On("GetByID", ctx, 7).Return(models.Group{}, fmt.Errorf("group with ID 7 %w", models.ErrNotFound))
+
+	_, err := groups.Songs(ctx, 7, 10, 0)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	mockRepo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestRenameGroupMergeOnConflict(t *testing.T) {
+	mockGroups := new(MockGroupRepo)
+	mockRepo := new(MockRepo)
+	mockLog := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx := context.Background()
+	groups := service.NewGroupService(mockGroups, mockRepo, NoopTx{}, mockLog)
+
+	mockGroups.On("GetByName", ctx, "Muse").Return(models.Group{ID: 8, Name: "Muse"}, nil)
+	mockGroups.On("Merge", ctx, 3, 8).Return(models.Group{ID: 8, Name: "Muse"}, nil)
+
+	group, err := groups.Rename(ctx, 3, models.GroupRename{Name: "Muse", OnConflict: models.OnConflictMerge})
+	assert.Nil(t, err)
+	assert.Equal(t, 8, group.ID)
+	mockGroups.AssertExpectations(t)
+}
+
+func TestRenameGroupConflictWithoutMerge(t *testing.T) {
+	mockGroups := new(MockGroupRepo)
+	mockRepo := new(MockRepo)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	groups := service.NewGroupService(mockGroups, mockRepo, NoopTx{}, &mockLog)
+
+	mockGroups.On("GetByName", ctx, "Muse").Return(models.Group{ID: 8, Name: "Muse"}, nil)
+
+	_, err := groups.Rename(ctx, 3, models.GroupRename{Name: "Muse"})
+	assert.ErrorIs(t, err, models.ErrConflict)
+	mockGroups.AssertNotCalled(t, "Rename", mock.Anything, mock.Anything, mock.Anything)
+	mockGroups.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchSongResolvesGroup(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
+
+	name := "Muse"
+	groupID := 8
+	mockGroups.On("Ensure", ctx, name).Return(models.Group{ID: groupID, Name: name}, nil)
+	mockRepo.On("Patch", ctx, 1, models.SongPatch{GroupName: &name, GroupID: &groupID}).Return(models.Song{ID: 1, GroupID: groupID, GroupName: name}, nil)
+
+	song, err := service.Patch(ctx, 1, models.SongPatch{GroupName: &name})
+	assert.Nil(t, err)
+	assert.Equal(t, groupID, song.GroupID)
+	mockGroups.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetSongsInvalidDateRange(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
+
+	filter := models.SongFilter{
+		ReleasedFrom: models.NewDate(2024, time.June, 1),
+		ReleasedTo:   models.NewDate(2024, time.January, 1),
+		Limit:        10,
+	}
+
+	_, err := service.Get(context.Background(), filter)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	mockRepo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestSearchValidation(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
+
+	_, err := service.Search(context.Background(), models.SearchQuery{Query: "   ", Limit: 10})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	_, err = service.Search(context.Background(), models.SearchQuery{Query: "love", Language: "de", Limit: 10})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	mockRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+}
+
+func TestGetSongsFuzzyNeedsName(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
+
+	_, err := service.Get(context.Background(), models.SongFilter{Match: models.MatchFuzzy, Limit: 10})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	mockRepo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestGetSongsCursorWithOffset(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
+
+	filter := models.SongFilter{After: &models.SongCursor{ID: 10}, Limit: 10, Offset: 20}
+
+	_, err := service.Get(context.Background(), filter)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	mockRepo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestVersesOnlyChoruses(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	song := models.Song{ID: 1, Text: "[Verse 1]\nfirst\n\n[Chorus]\nla la\n\n[Verse 2]\nsecond\n\n[Chorus]"}
+	mockRepo.On("GetByID", ctx, 1).Return(song, nil)
+
+	verses, total, err := service.Verses(ctx, 1, []string{models.SectionChorus}, 5, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []models.Verse{
+		{Number: 2, Type: models.SectionChorus, Lines: []string{"la la"}},
+		{Number: 4, Type: models.SectionChorus, Lines: []string{"la la"}},
+	}, verses)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportLRC(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	lyrics := models.TimedLyrics{Lines: []models.TimedLine{{Time: 1500, Text: "Yesterday"}}}
+	mockRepo.On("SetTimedLyrics", ctx, 1, lyrics).Return(nil)
+
+	imported, err := service.ImportLRC(ctx, 1, strings.NewReader("[00:01.50]Yesterday"))
+	assert.Nil(t, err)
+	assert.Equal(t, lyrics, imported)
+
+	_, err = service.ImportLRC(ctx, 1, strings.NewReader("just words"))
+	assert.ErrorIs(t, err, models.ErrValidation)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLyricsAtWithoutTimedLyrics(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, &mockLog)
+
+	mockRepo.On("GetByID", ctx, 1).Return(models.Song{ID: 1}, nil)
+
+	_, err := service.LyricsAt(ctx, 1, time.Second)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestCreateSongInvalidChordPro(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockClient := new(MockClient)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, nil, NoopTx{}, mockClient, &mockLog)
+
+	_, err := service.Create(ctx, models.Song{Song: "s", GroupName: "g", Text: "[C unclosed", TextFormat: models.TextFormatChordPro})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	_, err = service.Create(ctx, models.Song{Song: "s", GroupName: "g", TextFormat: "markdown"})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	mockClient.AssertNotCalled(t, "GetDetails", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchTextOfChordProSong(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, nil, NoopTx{}, nil, &mockLog)
+
+	text := "{soc}\nLet it [C]be\n{eoc}"
+	sections := models.Sections{{Type: models.SectionChorus, Label: "Chorus", Lines: []string{"Let it be"}}}
+
+	mockRepo.On("GetByID", ctx, 1).Return(models.Song{ID: 1, TextFormat: models.TextFormatChordPro}, nil)
+	mockRepo.On("Patch", ctx, 1, models.SongPatch{Text: &text, Sections: &sections, UserFields: models.FieldSet{models.FieldText}}).Return(models.Song{ID: 1, Text: text}, nil)
+
+	_, err := service.Patch(ctx, 1, models.SongPatch{Text: &text})
+	assert.Nil(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChords(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, nil, NoopTx{}, nil, &mockLog)
+
+	mockRepo.On("GetByID", ctx, 1).Return(models.Song{ID: 1, Text: "{key: C}\n[C]Let it [G/B]be", TextFormat: models.TextFormatChordPro}, nil)
+	mockRepo.On("GetByID", ctx, 2).Return(models.Song{ID: 2, Text: "Let it be", TextFormat: models.TextFormatPlain}, nil)
+
+	sheet, err := service.Chords(ctx, 1, -2, models.NotationGerman)
+	assert.Nil(t, err)
+	assert.Equal(t, "B", sheet.Key)
+	assert.Equal(t, []models.ChordPosition{{Offset: 0, Chord: "B"}, {Offset: 7, Chord: "F/A"}}, sheet.Sections[0].Lines[0].Chords)
+
+	_, err = service.Chords(ctx, 2, 0, models.NotationLatin)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+
+	_, err = service.Chords(ctx, 1, 12, models.NotationLatin)
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestCreateSongEnrichmentDisabled(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockLog := slog.Logger{}
+
+	ctx := context.Background()
+	service := service.NewService(mockRepo, mockGroups, NoopTx{}, client.Disabled{}, &mockLog)
+
+	song := models.Song{Song: "SongName", GroupName: "GroupName", Link: "some-link"}
+
+	mockGroups.On("Ensure", ctx, song.GroupName).Return(models.Group{ID: 3, Name: song.GroupName}, nil)
+	mockRepo.On("Create", ctx, mock.MatchedBy(func(song models.Song) bool {
+		return song.Enrichment.Status == models.EnrichmentSkipped
+	})).Return(7, nil)
+
+	created, err := service.Create(ctx, song)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, created.ID)
+	assert.Equal(t, "some-link", created.Link)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRefresh(t *testing.T) {
+	stored := models.Song{ID: 1, Song: "SongName", GroupName: "GroupName", Text: "old text", TextFormat: models.TextFormatPlain, ReleaseDate: models.NewDate(2024, time.January, 1)}
+	details := models.Song{Text: "new text", Link: "upstream-link", ReleaseDate: models.NewDate(2024, time.January, 1).In(models.ISODateLayout)}
+
+	for _, tc := range []struct {
+		apply   string
+		applied []bool
+		patch   func() models.SongPatch
+	}{
+		{apply: "", applied: []bool{false, false}},
+		{apply: models.RefreshApplyMissing, applied: []bool{false, true}, patch: func() models.SongPatch {
+			return models.SongPatch{Link: &details.Link}
+		}},
+		{apply: models.RefreshApplyAll, applied: []bool{true, true}, patch: func() models.SongPatch {
+			format := models.TextFormatPlain
+			sections := models.Sections{{Type: models.SectionVerse, Number: 1, Lines: []string{"new text"}}}
+			return models.SongPatch{Text: &details.Text, TextFormat: &format, Sections: &sections, Link: &details.Link}
+		}},
+	} {
+		t.Run(tc.apply, func(t *testing.T) {
+			mockRepo := new(MockRepo)
+			mockClient := new(MockClient)
+			svc := service.NewService(mockRepo, nil, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			ctx := context.Background()
+			mockRepo.On("GetByID", ctx, 1).Return(stored, nil)
+			mockClient.On("GetDetails", mock.Anything, "SongName", "GroupName").Return(details, nil)
+			if tc.patch != nil {
+				mockRepo.On("Patch", ctx, 1, tc.patch()).Return(stored, nil)
+				mockRepo.On("GetEnrichment", ctx, 1).Return(models.Enrichment{Status: models.EnrichmentFailed, Attempts: 5}, nil)
+				mockRepo.On("SetEnrichment", ctx, 1, models.Enrichment{Status: models.EnrichmentDone, Attempts: 6}).Return(nil)
+			}
+
+			res, err := svc.Refresh(ctx, 1, tc.apply, false)
+			assert.Nil(t, err)
+
+			// the release dates are the same day in another layout
+			assert.Equal(t, []models.FieldDiff{
+				{Field: models.FieldText, Stored: "old text", Upstream: "new text", Applied: tc.applied[0]},
+				{Field: models.FieldLink, Stored: "", Upstream: "upstream-link", Applied: tc.applied[1]},
+			}, res.Diff)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+
+	svc := service.NewService(new(MockRepo), nil, NoopTx{}, new(MockClient), &slog.Logger{})
+	_, err := svc.Refresh(context.Background(), 1, "some", false)
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestRefreshChordPro(t *testing.T) {
+	stored := models.Song{ID: 1, Song: "SongName", GroupName: "GroupName", Text: "[G]old text", TextFormat: models.TextFormatChordPro}
+	details := models.Song{Text: "new text"}
+
+	for _, overwrite := range []bool{false, true} {
+		t.Run(strconv.FormatBool(overwrite), func(t *testing.T) {
+			mockRepo := new(MockRepo)
+			mockClient := new(MockClient)
+			svc := service.NewService(mockRepo, nil, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			ctx := context.Background()
+			mockRepo.On("GetByID", ctx, 1).Return(stored, nil)
+			mockClient.On("GetDetails", mock.Anything, "SongName", "GroupName").Return(details, nil)
+			mockRepo.On("GetEnrichment", ctx, 1).Return(models.Enrichment{Status: models.EnrichmentDone, Attempts: 1}, nil)
+			mockRepo.On("SetEnrichment", ctx, 1, models.Enrichment{Status: models.EnrichmentDone, Attempts: 2}).Return(nil)
+			if overwrite {
+				// only an explicit overwrite replaces the chords with the plain text
+				format := models.TextFormatPlain
+				sections := models.Sections{{Type: models.SectionVerse, Number: 1, Lines: []string{"new text"}}}
+				mockRepo.On("Patch", ctx, 1, models.SongPatch{Text: &details.Text, TextFormat: &format, Sections: &sections}).Return(stored, nil)
+			}
+
+			res, err := svc.Refresh(ctx, 1, models.RefreshApplyAll, overwrite)
+			assert.Nil(t, err)
+			assert.Equal(t, []models.FieldDiff{{Field: models.FieldText, Stored: "[G]old text", Upstream: "new text", Applied: overwrite}}, res.Diff)
+			mockRepo.AssertExpectations(t)
+			if !overwrite {
+				mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestRefreshRereadsSong(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockClient := new(MockClient)
+	svc := service.NewService(mockRepo, nil, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx := context.Background()
+	stored := models.Song{ID: 1, Song: "SongName", GroupName: "GroupName"}
+	mockRepo.On("GetByID", ctx, 1).Return(stored, nil).Once()
+	// the link is set while the upstream is asked
+	edited := stored
+	edited.Link = "user-link"
+	mockRepo.On("GetByID", ctx, 1).Return(edited, nil).Once()
+	mockClient.On("GetDetails", mock.Anything, "SongName", "GroupName").Return(models.Song{Link: "upstream-link"}, nil)
+	mockRepo.On("GetEnrichment", ctx, 1).Return(models.Enrichment{Status: models.EnrichmentDone, Attempts: 1}, nil)
+	mockRepo.On("SetEnrichment", ctx, 1, models.Enrichment{Status: models.EnrichmentDone, Attempts: 2}).Return(nil)
+
+	res, err := svc.Refresh(ctx, 1, models.RefreshApplyMissing, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.FieldDiff{{Field: models.FieldLink, Stored: "user-link", Upstream: "upstream-link", Applied: false}}, res.Diff)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRefreshGroup(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	svc := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx := context.Background()
+	songs := []models.Song{
+		{ID: 1, Song: "Known", GroupName: "GroupName", Link: "link"},
+		{ID: 2, Song: "Unknown", GroupName: "GroupName"},
+	}
+	mockGroups.On("GetByID", ctx, 3).Return(models.Group{ID: 3, Name: "GroupName"}, nil)
+	mockRepo.On("Get", ctx, models.SongFilter{GroupID: 3, Limit: 2, Offset: 0}).Return(models.SongPage{Songs: songs, Total: 5, HasNext: true}, nil)
+	mockClient.On("GetDetails", mock.Anything, "Known", "GroupName").Return(models.Song{Link: "new-link"}, nil)
+	mockClient.On("GetDetails", mock.Anything, "Unknown", "GroupName").Return(models.Song{}, &client.StatusError{Code: 404, Status: "404 Not Found"})
+
+	page, err := svc.RefreshGroup(ctx, 3, models.RefreshApplyNone, false, 2, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, page.Total)
+	assert.True(t, page.HasNext)
+	assert.Len(t, page.Refreshes, 2)
+
+	assert.Equal(t, []models.FieldDiff{{Field: models.FieldLink, Stored: "link", Upstream: "new-link"}}, page.Refreshes[0].Diff)
+	assert.Empty(t, page.Refreshes[0].Error)
+	assert.Equal(t, 2, page.Refreshes[1].ID)
+	assert.Contains(t, page.Refreshes[1].Error, "404 Not Found")
+
+	mockGroups.On("GetByID", ctx, 4).Return(models.Group{}, fmt.Errorf("group with ID 4 %w", models.ErrNotFound))
+	_, err = svc.RefreshGroup(ctx, 4, "", false, 2, 0)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestRefreshGroupSongErrors(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockGroups := new(MockGroupRepo)
+	mockClient := new(MockClient)
+	svc := service.NewService(mockRepo, mockGroups, NoopTx{}, mockClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx := context.Background()
+	songs := []models.Song{
+		{ID: 1, Song: "Deleted", GroupName: "GroupName"},
+		{ID: 2, Song: "Refreshed", GroupName: "GroupName"},
+	}
+	link := "new-link"
+	mockGroups.On("GetByID", ctx, 3).Return(models.Group{ID: 3, Name: "GroupName"}, nil)
+	mockRepo.On("Get", ctx, models.SongFilter{GroupID: 3, Limit: 2}).Return(models.SongPage{Songs: songs, Total: 2}, nil)
+	mockClient.On("GetDetails", mock.Anything, mock.Anything, "GroupName").Return(models.Song{Link: link}, nil)
+	// the first song is deleted while the upstream is asked
+	mockRepo.On("GetByID", mock.Anything, 1).Return(models.Song{}, fmt.Errorf("song with ID 1 %w", models.ErrNotFound))
+	mockRepo.On("GetByID", mock.Anything, 2).Return(songs[1], nil)
+	mockRepo.On("Patch", mock.Anything, 2, models.SongPatch{Link: &link}).Return(models.Song{ID: 2}, nil)
+	mockRepo.On("GetEnrichment", mock.Anything, 2).Return(models.Enrichment{Status: models.EnrichmentDone, Attempts: 1}, nil)
+	mockRepo.On("SetEnrichment", mock.Anything, 2, mock.Anything).Return(nil)
+
+	page, err := svc.RefreshGroup(ctx, 3, models.RefreshApplyMissing, false, 2, 0)
+	assert.Nil(t, err)
+	assert.Len(t, page.Refreshes, 2)
+	assert.Contains(t, page.Refreshes[0].Error, "not found")
+	assert.Empty(t, page.Refreshes[1].Error)
+	assert.True(t, page.Refreshes[1].Diff[0].Applied)
+
+	// a storage failure fails the page
+	mockRepo.On("Get", ctx, models.SongFilter{GroupID: 3, Limit: 1, Offset: 1}).Return(models.SongPage{Songs: songs[1:], Total: 2}, nil)
+	mockRepo.On("GetEnrichment", mock.Anything, 2).Unset()
+	mockRepo.On("GetEnrichment", mock.Anything, 2).Return(models.Enrichment{}, errors.New("connection reset"))
+
+	_, err = svc.RefreshGroup(ctx, 3, models.RefreshApplyMissing, false, 1, 1)
+	assert.ErrorContains(t, err, "connection reset")
 }
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/lrc"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// ImportLRC parses an LRC file and stores it as the timed lyrics of the song,
+// replacing the previous ones
+func (s *Service) ImportLRC(ctx context.Context, id int, r io.Reader) (models.TimedLyrics, error) {
+	lyrics, err := lrc.Parse(r)
+	if err != nil {
+		return models.TimedLyrics{}, fmt.Errorf("%w: invalid lrc: %w", models.ErrValidation, err)
+	}
+
+	if err := s.Repo.SetTimedLyrics(ctx, id, lyrics); err != nil {
+		return models.TimedLyrics{}, err
+	}
+
+	s.log.Debug("timed lyrics imported", slog.Int("song_id", id), slog.Int("lines", len(lyrics.Lines)))
+
+	return lyrics, nil
+}
+
+// TimedLyrics returns the timed lyrics of the song, a song without them is
+// reported as not found
+func (s *Service) TimedLyrics(ctx context.Context, id int) (models.TimedLyrics, error) {
+	song, err := s.Repo.GetByID(ctx, id)
+	if err != nil {
+		return models.TimedLyrics{}, err
+	}
+
+	if song.TimedLyrics == nil {
+		return models.TimedLyrics{}, fmt.Errorf("timed lyrics of song with ID %d %w", id, models.ErrNotFound)
+	}
+
+	return *song.TimedLyrics, nil
+}
+
+// LyricsAt returns the line of the timed lyrics active at the playback
+// position t
+func (s *Service) LyricsAt(ctx context.Context, id int, t time.Duration) (models.LyricsPosition, error) {
+	if t < 0 {
+		return models.LyricsPosition{}, fmt.Errorf("%w: position can't be negative", models.ErrValidation)
+	}
+
+	lyrics, err := s.TimedLyrics(ctx, id)
+	if err != nil {
+		return models.LyricsPosition{}, err
+	}
+
+	return lrc.At(lyrics, t), nil
+}
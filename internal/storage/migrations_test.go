@@ -0,0 +1,94 @@
+package storage_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+const migrationsDir = "../../migrations"
+
+// testDB opens the database in TEST_DATABASE_URL with all migrations rolled
+// back. The database is wiped, never point it at one holding real data.
+func testDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.DownTo(db, migrationsDir, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestReleaseDateMigration(t *testing.T) {
+	db := testDB(t)
+
+	if err := goose.UpTo(db, migrationsDir, 20261018090000); err != nil {
+		t.Fatal(err)
+	}
+
+	var groupID int
+	if err := db.QueryRow(`INSERT INTO groups (name) VALUES ('Group') RETURNING id`).Scan(&groupID); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := map[string]*time.Time{
+		"16.07.2006":           ptr(time.Date(2006, time.July, 16, 0, 0, 0, 0, time.UTC)),
+		"2006-07-16":           ptr(time.Date(2006, time.July, 16, 0, 0, 0, 0, time.UTC)),
+		"2006-07-16T10:00:00Z": ptr(time.Date(2006, time.July, 16, 0, 0, 0, 0, time.UTC)),
+		// in the format, but not dates
+		"31.02.2020": nil,
+		"2020-13-01": nil,
+		"00.00.0000": nil,
+		"":           nil,
+		"soon":       nil,
+	}
+	for val := range legacy {
+		if _, err := db.Exec(`INSERT INTO songs (group_id, song, text, link, releasedate) VALUES ($1, $2, '', '', $2)`, groupID, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the malformed rows don't abort the migration
+	if err := goose.UpTo(db, migrationsDir, 20261018100000); err != nil {
+		t.Fatal(err)
+	}
+
+	for val, want := range legacy {
+		var got sql.NullTime
+		if err := db.QueryRow(`SELECT releasedate FROM songs WHERE song = $1`, val).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+
+		if want == nil {
+			assert.False(t, got.Valid, val)
+			continue
+		}
+		assert.True(t, got.Valid, val)
+		assert.True(t, want.Equal(got.Time.UTC()), val)
+	}
+
+	// the rest of the migrations run on the converted rows
+	assert.NoError(t, goose.Up(db, migrationsDir))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
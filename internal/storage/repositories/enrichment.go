@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/Fyefhqdishka/eff-mobile/internal/storage"
+	"log/slog"
+	"time"
+)
+
+// ClaimEnrichment takes up to limit pending songs that are due and moves their
+// next attempt lease into the future, so that no other worker takes them in
+// the meantime. Songs whose worker never reports back are due again once the
+// lease runs out.
+func (r *SongRepository) ClaimEnrichment(ctx context.Context, limit int, lease time.Duration) ([]models.EnrichmentJob, error) {
+	stmt := `WITH due AS (
+                 SELECT id FROM songs
+                 WHERE enrichment_status = 'pending' AND enrichment_next_at <= now()
+                 ORDER BY enrichment_next_at, id
+                 LIMIT $1
+                 FOR UPDATE SKIP LOCKED
+             ), claimed AS (
+                 UPDATE songs s SET enrichment_next_at = now() + make_interval(secs => $2)
+                 FROM due
+                 WHERE s.id = due.id
+                 RETURNING s.id, s.group_id, s.song, s.enrichment_attempts,
+                           s.text <> '' AND s.link <> '' AND s.releasedate IS NOT NULL AS complete,
+                           s.enrichment_next_at
+             )
+             SELECT c.id, c.song, g.name, c.enrichment_attempts, c.complete, c.enrichment_next_at
+             FROM claimed c
+             JOIN groups g ON g.id = c.group_id
+             ORDER BY c.id`
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, stmt, limit, lease.Seconds())
+	if err != nil {
+		r.log.Error("can't claim songs to enrich", slog.Any("error", err))
+		return nil, fmt.Errorf("can't claim songs to enrich, err=%w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.EnrichmentJob
+	for rows.Next() {
+		var job models.EnrichmentJob
+		if err := rows.Scan(&job.ID, &job.Song, &job.GroupName, &job.Attempts, &job.Complete, &job.ClaimedUntil); err != nil {
+			r.log.Error("error scannin row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scannin row, err=%w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.log.Error("rows error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows error, err=%w", err)
+	}
+
+	if len(jobs) > 0 {
+		r.log.Debug("songs claimed for enrichment", slog.Int("count", len(jobs)))
+	}
+
+	return jobs, nil
+}
+
+// SetEnrichment stores the enrichment state of the song. Only pending songs
+// keep their next attempt time. The sources are added to the stored ones,
+// fields not enriched this time keep theirs.
+func (r *SongRepository) SetEnrichment(ctx context.Context, id int, state models.Enrichment) error {
+	return r.setEnrichment(ctx, id, nil, state)
+}
+
+// ReleaseEnrichment stores the outcome of a claimed enrichment like
+// SetEnrichment, as long as the song is still claimed until claimedUntil.
+// Once the claim ran out and the song was claimed again or its enrichment
+// finished meanwhile, the outcome is rejected with ErrConflict.
+func (r *SongRepository) ReleaseEnrichment(ctx context.Context, id int, claimedUntil time.Time, state models.Enrichment) error {
+	return r.setEnrichment(ctx, id, &claimedUntil, state)
+}
+
+func (r *SongRepository) setEnrichment(ctx context.Context, id int, claimedUntil *time.Time, state models.Enrichment) error {
+	r.log.Debug("start storing enrichment state", slog.Int("song_id", id), slog.String("status", state.Status))
+
+	conn := storage.Conn(ctx, r.db)
+
+	stmt := `UPDATE songs
+             SET enrichment_status = $1, enrichment_attempts = $2, enrichment_error = NULLIF($3, ''),
+                 enrichment_next_at = COALESCE($4, now()),
+                 enrichment_sources = COALESCE(enrichment_sources, '{}'::jsonb) || COALESCE($5::jsonb, '{}'::jsonb)
+             WHERE id = $6
+               AND ($7::timestamptz IS NULL OR enrichment_status = 'pending' AND enrichment_next_at = $7)`
+	res, err := conn.ExecContext(ctx, stmt, state.Status, state.Attempts, state.LastError, state.NextAttemptAt, state.Sources, id, claimedUntil)
+	if err != nil {
+		r.log.Error("can't store enrichment state", slog.Int("song_id", id), slog.Any("error", err))
+		return fmt.Errorf("can't store enrichment state, err=%w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.log.Error("failed to fetch rows affected", slog.Any("error", err))
+		return fmt.Errorf("failed to fetch rows affected, err=%w", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if claimedUntil != nil {
+		if err := conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM songs WHERE id = $1)`, id).Scan(&exists); err != nil {
+			r.log.Error("can't check song existence", slog.Int("song_id", id), slog.Any("error", err))
+			return fmt.Errorf("can't check song existence, err=%w", err)
+		}
+	}
+	if exists {
+		r.log.Debug("enrichment claim lost", slog.Int("song_id", id))
+		return fmt.Errorf("enrichment claim of song %d ran out: %w", id, models.ErrConflict)
+	}
+
+	r.log.Debug("song not found", slog.Int("song_id", id))
+	return fmt.Errorf("song with ID %d %w", id, models.ErrNotFound)
+}
+
+// GetEnrichment returns the enrichment state of the song
+func (r *SongRepository) GetEnrichment(ctx context.Context, id int) (models.Enrichment, error) {
+	r.log.Debug("start retrieving enrichment state", slog.Int("song_id", id))
+
+	stmt := `SELECT enrichment_status, enrichment_attempts, COALESCE(enrichment_error, ''),
+                    CASE WHEN enrichment_status = 'pending' THEN enrichment_next_at END,
+                    enrichment_sources
+             FROM songs
+             WHERE id = $1`
+
+	var state models.Enrichment
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, id).Scan(&state.Status, &state.Attempts, &state.LastError, &state.NextAttemptAt, &state.Sources)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.log.Debug("song not found", slog.Int("song_id", id))
+		return models.Enrichment{}, fmt.Errorf("song with ID %d %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		r.log.Error("can't fetch enrichment state", slog.Int("song_id", id), slog.Any("error", err))
+		return models.Enrichment{}, fmt.Errorf("can't fetch enrichment state, err=%w", err)
+	}
+
+	return state, nil
+}
@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/Fyefhqdishka/eff-mobile/internal/storage"
+	"github.com/lib/pq"
+	"log/slog"
+)
+
+// uniqueViolation is the postgres error code for unique constraint violations
+const uniqueViolation = "23505"
+
+type GroupRepository struct {
+	db  *sql.DB
+	log *slog.Logger
+}
+
+func NewGroupRepository(db *sql.DB, log *slog.Logger) *GroupRepository {
+	return &GroupRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *GroupRepository) Create(ctx context.Context, name string) (models.Group, error) {
+	r.log.Debug("Starting to create a group", slog.String("group_name", name))
+
+	stmt := `INSERT INTO groups (name) VALUES ($1) RETURNING id, name`
+
+	var group models.Group
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, name).Scan(&group.ID, &group.Name)
+	if isUniqueViolation(err) {
+		return models.Group{}, fmt.Errorf("group %q already exists: %w", name, models.ErrConflict)
+	}
+	if err != nil {
+		r.log.Error("Failed to insert group", slog.String("group_name", name), slog.Any("error", err))
+		return models.Group{}, fmt.Errorf("can't insert group, err=%w", err)
+	}
+
+	r.log.Debug("Group successfully created", slog.Int("group_id", group.ID))
+
+	return group, nil
+}
+
+// Ensure returns the group with the given name, creating it if needed. An
+// existing group is only read, so songs of one group can be written
+// concurrently without locking the group row.
+func (r *GroupRepository) Ensure(ctx context.Context, name string) (models.Group, error) {
+	conn := storage.Conn(ctx, r.db)
+
+	stmt := `INSERT INTO groups (name) VALUES ($1)
+             ON CONFLICT (name) DO NOTHING
+             RETURNING id, name`
+
+	var group models.Group
+	err := conn.QueryRowContext(ctx, stmt, name).Scan(&group.ID, &group.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = conn.QueryRowContext(ctx, `SELECT id, name FROM groups WHERE name = $1`, name).Scan(&group.ID, &group.Name)
+	}
+	if err != nil {
+		r.log.Error("Failed to ensure group existence", slog.String("group_name", name), slog.Any("error", err))
+		return models.Group{}, fmt.Errorf("failed to ensure group %s existence, err=%w", name, err)
+	}
+
+	return group, nil
+}
+
+func (r *GroupRepository) Rename(ctx context.Context, id int, name string) (models.Group, error) {
+	r.log.Debug("Starting to rename a group", slog.Int("group_id", id), slog.String("group_name", name))
+
+	stmt := `UPDATE groups SET name = $1 WHERE id = $2 RETURNING id, name`
+
+	var group models.Group
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, name, id).Scan(&group.ID, &group.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Group{}, fmt.Errorf("group with ID %d %w", id, models.ErrNotFound)
+	}
+	if isUniqueViolation(err) {
+		return models.Group{}, fmt.Errorf("group %q already exists: %w", name, models.ErrConflict)
+	}
+	if err != nil {
+		r.log.Error("can't rename group", slog.Int("group_id", id), slog.Any("error", err))
+		return models.Group{}, fmt.Errorf("can't rename group, err=%w", err)
+	}
+
+	r.log.Debug("group renamed", slog.Int("group_id", id))
+
+	return group, nil
+}
+
+// Merge moves all songs of group fromID into group intoID and deletes fromID.
+// Run it inside a transaction so a failure can't leave songs half moved.
+func (r *GroupRepository) Merge(ctx context.Context, fromID, intoID int) (models.Group, error) {
+	r.log.Debug("Starting to merge groups", slog.Int("from_id", fromID), slog.Int("into_id", intoID))
+
+	group, err := r.GetByID(ctx, intoID)
+	if err != nil {
+		return models.Group{}, err
+	}
+
+	res, err := storage.Conn(ctx, r.db).ExecContext(ctx, `UPDATE songs SET group_id = $1 WHERE group_id = $2`, intoID, fromID)
+	if err != nil {
+		r.log.Error("can't move songs", slog.Int("from_id", fromID), slog.Any("error", err))
+		return models.Group{}, fmt.Errorf("can't move songs, err=%w", err)
+	}
+	moved, _ := res.RowsAffected()
+
+	if _, err = r.Delete(ctx, fromID); err != nil {
+		return models.Group{}, err
+	}
+
+	r.log.Debug("groups merged", slog.Int("from_id", fromID), slog.Int("into_id", intoID), slog.Int64("songs_moved", moved))
+
+	return group, nil
+}
+
+// Delete removes the group together with its songs
+func (r *GroupRepository) Delete(ctx context.Context, id int) (int, error) {
+	r.log.Debug("starting to delete a group", slog.Int("group_id", id))
+
+	stmt := `DELETE FROM groups WHERE id = $1 RETURNING id`
+
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("group with ID %d %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		r.log.Error("can't delete group", slog.Int("group_id", id), slog.Any("error", err))
+		return 0, fmt.Errorf("can't delete group, err=%w", err)
+	}
+
+	r.log.Debug("group deleted", slog.Int("group_id", id))
+
+	return id, nil
+}
+
+func (r *GroupRepository) GetByID(ctx context.Context, id int) (models.Group, error) {
+	stmt := `SELECT id, name FROM groups WHERE id = $1`
+
+	var group models.Group
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, id).Scan(&group.ID, &group.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Group{}, fmt.Errorf("group with ID %d %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		r.log.Error("can't fetch group", slog.Int("group_id", id), slog.Any("error", err))
+		return models.Group{}, fmt.Errorf("can't fetch group, err=%w", err)
+	}
+
+	return group, nil
+}
+
+func (r *GroupRepository) GetByName(ctx context.Context, name string) (models.Group, error) {
+	stmt := `SELECT id, name FROM groups WHERE name = $1`
+
+	var group models.Group
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, name).Scan(&group.ID, &group.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Group{}, fmt.Errorf("group %q %w", name, models.ErrNotFound)
+	}
+	if err != nil {
+		r.log.Error("can't fetch group", slog.String("group_name", name), slog.Any("error", err))
+		return models.Group{}, fmt.Errorf("can't fetch group, err=%w", err)
+	}
+
+	return group, nil
+}
+
+func (r *GroupRepository) Get(ctx context.Context, limit, offset int) ([]models.Group, error) {
+	r.log.Debug("start retrieving groups", slog.Int("limit", limit), slog.Int("offset", offset))
+
+	stmt := `SELECT id, name FROM groups ORDER BY name, id LIMIT $1 OFFSET $2`
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, stmt, limit, offset)
+	if err != nil {
+		r.log.Error("can't fetch groups", slog.Any("error", err))
+		return nil, fmt.Errorf("can't fetch groups, err=%w", err)
+	}
+	defer rows.Close()
+
+	groups := []models.Group{}
+	for rows.Next() {
+		var group models.Group
+		if err = rows.Scan(&group.ID, &group.Name); err != nil {
+			r.log.Error("error scannin row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scannin row, err=%w", err)
+		}
+		groups = append(groups, group)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.log.Error("rows error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows error, err=%w", err)
+	}
+
+	return groups, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
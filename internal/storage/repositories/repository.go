@@ -1,10 +1,15 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/Fyefhqdishka/eff-mobile/internal/storage"
 	"log/slog"
+	"strings"
+	"time"
 )
 
 type SongRepository struct {
@@ -19,25 +24,24 @@ func NewSongRepository(db *sql.DB, log *slog.Logger) *SongRepository {
 	}
 }
 
-func (r *SongRepository) Create(song models.Song) (int, error) {
+func (r *SongRepository) Create(ctx context.Context, song models.Song) (int, error) {
 	r.log.Debug("Starting to create a song", slog.String("song", song.Song), slog.String("group_name", song.GroupName))
 
-	createGroup := `INSERT INTO groups (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`
-	_, err := r.db.Exec(createGroup, song.GroupName)
-	if err != nil {
-		r.log.Error("Failed to ensure group existence", slog.String("group_name", song.GroupName), slog.Any("error", err))
-		return 0, fmt.Errorf("failed to ensure group %s existence, err=%v", song.GroupName, err)
+	// songs created without an enrichment state need none
+	status := models.EnrichmentDone
+	if song.Enrichment != nil {
+		status = song.Enrichment.Status
 	}
 
-	stmt := `INSERT INTO songs (group_name, song, text, link, releaseDate) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	stmt := `INSERT INTO songs (group_id, song, text, text_format, sections, link, releaseDate, enrichment_status, user_fields) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
 	var songID int
-	err = r.db.QueryRow(stmt, song.GroupName, song.Song, song.Text, song.Link, song.ReleaseDate).Scan(&songID)
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, song.GroupID, song.Song, song.Text, song.TextFormat, song.Sections, song.Link, song.ReleaseDate, status, song.UserFields).Scan(&songID)
 	if err != nil {
 		r.log.Error("Failed to insert song into database",
 			slog.String("song", song.Song),
 			slog.String("group_name", song.GroupName),
 			slog.Any("error", err))
-		return 0, fmt.Errorf("can't insert into db, err=%v", err)
+		return 0, fmt.Errorf("can't insert into db, err=%w", err)
 	}
 
 	r.log.Debug("Song successfully created", slog.Int("song_id", songID), slog.String("song", song.Song))
@@ -45,95 +49,425 @@ func (r *SongRepository) Create(song models.Song) (int, error) {
 	return songID, nil
 }
 
-func (r *SongRepository) Update(song models.Song) (bool, error) {
+func (r *SongRepository) Update(ctx context.Context, song models.Song) (bool, error) {
 	r.log.Debug("Starting to update a song", slog.String("song", song.Song), slog.String("group_name", song.GroupName))
 
-	updateGroupNameStmt := `UPDATE groups SET name = $1`
-	_, err := r.db.Exec(updateGroupNameStmt, song.GroupName)
-	if err != nil {
-		r.log.Error("failed to update group name, err", err)
-		return false, fmt.Errorf("failed to update group name, err=%v", err)
-	}
-
-	stmt := `UPDATE songs SET song = $1, group_name = $2, text = $3, link = $4, releasedate = $5 WHERE id = $6`
-	res, err := r.db.Exec(stmt, song.Song, song.GroupName, song.Text, song.Link, song.ReleaseDate, song.ID)
+	stmt := `UPDATE songs SET song = $1, group_id = $2, text = $3, text_format = $4, sections = $5, link = $6, releasedate = $7, user_fields = $8 WHERE id = $9`
+	res, err := storage.Conn(ctx, r.db).ExecContext(ctx, stmt, song.Song, song.GroupID, song.Text, song.TextFormat, song.Sections, song.Link, song.ReleaseDate, song.UserFields, song.ID)
 	if err != nil {
-		r.log.Error("can't fetch rows affected, err", err)
-		return false, fmt.Errorf("can't fetch rows affected, err=%v", err)
+		r.log.Error("can't fetch rows affected", slog.Any("error", err))
+		return false, fmt.Errorf("can't fetch rows affected, err=%w", err)
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		r.log.Error("failed to fetch rows affected, err", err)
-		return false, fmt.Errorf("failed to fetch rows affected, err=%v", err)
+		r.log.Error("failed to fetch rows affected", slog.Any("error", err))
+		return false, fmt.Errorf("failed to fetch rows affected, err=%w", err)
 	}
 
 	if rowsAffected == 0 {
-		r.log.Error("song with id", song.ID, "not found")
-		return false, fmt.Errorf("song with ID %d not found", song.ID)
+		r.log.Error("song not found", slog.Int("song_id", song.ID))
+		return false, fmt.Errorf("song with ID %d %w", song.ID, models.ErrNotFound)
 	}
 
-	r.log.Debug("song:", song.Song, " updated")
+	r.log.Debug("song updated", slog.String("song", song.Song))
 
 	return true, nil
 }
 
-func (r *SongRepository) Delete(song models.Song) (int, error) {
-	r.log.Debug("starting to delete a song", song.Song, song.GroupName)
+func (r *SongRepository) Patch(ctx context.Context, id int, patch models.SongPatch) (models.Song, error) {
+	r.log.Debug("Starting to patch a song", slog.Int("song_id", id))
+
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if patch.GroupID != nil {
+		set("group_id", *patch.GroupID)
+	}
+	if patch.Song != nil {
+		set("song", *patch.Song)
+	}
+	if patch.Text != nil {
+		set("text", *patch.Text)
+	}
+	if patch.TextFormat != nil {
+		set("text_format", *patch.TextFormat)
+	}
+	if patch.Sections != nil {
+		set("sections", *patch.Sections)
+	}
+	if patch.Link != nil {
+		set("link", *patch.Link)
+	}
+	if patch.ReleaseDate != nil {
+		set("releasedate", *patch.ReleaseDate)
+	}
+	if patch.UserFields != nil {
+		args = append(args, patch.UserFields)
+		sets = append(sets, fmt.Sprintf(`user_fields = (
+                     SELECT COALESCE(jsonb_agg(DISTINCT f), '[]'::jsonb)
+                     FROM jsonb_array_elements_text(user_fields || $%d::jsonb) f)`, len(args)))
+	}
+
+	args = append(args, id)
+	stmt := fmt.Sprintf(`WITH updated AS (
+                 UPDATE songs SET %s WHERE id = $%d
+                 RETURNING id, group_id, song, text, text_format, link, releasedate
+             )
+             SELECT u.id, u.group_id, g.name, u.song, u.text, u.text_format, u.link, u.releasedate
+             FROM updated u
+             JOIN groups g ON g.id = u.group_id`, strings.Join(sets, ", "), len(args))
+
+	var song models.Song
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, args...).Scan(&song.ID, &song.GroupID, &song.GroupName, &song.Song, &song.Text, &song.TextFormat, &song.Link, &song.ReleaseDate)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.log.Debug("song not found", slog.Int("song_id", id))
+		return models.Song{}, fmt.Errorf("song with ID %d %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		r.log.Error("can't patch song", slog.Int("song_id", id), slog.Any("error", err))
+		return models.Song{}, fmt.Errorf("can't patch song, err=%w", err)
+	}
+
+	r.log.Debug("song patched", slog.Int("song_id", id), slog.Int("fields", len(sets)))
+
+	return song, nil
+}
+
+func (r *SongRepository) Delete(ctx context.Context, id int) (int, error) {
+	r.log.Debug("starting to delete a song", slog.Int("song_id", id))
 
 	stmt := `DELETE FROM songs WHERE id = $1 RETURNING id`
 
-	err := r.db.QueryRow(stmt, song.ID).Scan(&song.ID)
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.log.Debug("song not found", slog.Int("song_id", id))
+		return 0, fmt.Errorf("song with ID %d %w", id, models.ErrNotFound)
+	}
 	if err != nil {
-		r.log.Error("can't delete song with id", song.ID, "err:", err)
-		return 0, fmt.Errorf("can't delete song, err=%v", err)
+		r.log.Error("can't delete song", slog.Int("song_id", id), slog.Any("error", err))
+		return 0, fmt.Errorf("can't delete song, err=%w", err)
 	}
 
-	r.log.Debug("song:", song.Song, " deleted")
+	r.log.Debug("song deleted", slog.Int("song_id", id))
 
-	return song.ID, nil
+	return id, nil
 }
 
-func (r *SongRepository) Get(groupName, songName, releaseDate string, limit, offset, songID int) ([]models.Song, error) {
+func (r *SongRepository) GetByID(ctx context.Context, id int) (models.Song, error) {
+	r.log.Debug("start retrieving song by id", slog.Int("song_id", id))
+
+	stmt := `SELECT s.id, s.group_id, g.name, s.song, s.text, s.text_format, s.sections, s.timed_lyrics, s.link, s.releasedate, s.user_fields
+             FROM songs s
+             JOIN groups g on s.group_id = g.id
+             WHERE s.id = $1`
+
+	var song models.Song
+	err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, id).Scan(&song.ID, &song.GroupID, &song.GroupName, &song.Song, &song.Text, &song.TextFormat, &song.Sections, &song.TimedLyrics, &song.Link, &song.ReleaseDate, &song.UserFields)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.log.Debug("song not found", slog.Int("song_id", id))
+		return models.Song{}, fmt.Errorf("song with ID %d %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		r.log.Error("can't fetch song", slog.Int("song_id", id), slog.Any("error", err))
+		return models.Song{}, fmt.Errorf("can't fetch song, err=%w", err)
+	}
+
+	return song, nil
+}
+
+// SetTimedLyrics replaces the timed lyrics of the song
+func (r *SongRepository) SetTimedLyrics(ctx context.Context, id int, lyrics models.TimedLyrics) error {
+	r.log.Debug("start storing timed lyrics", slog.Int("song_id", id), slog.Int("lines", len(lyrics.Lines)))
+
+	stmt := `UPDATE songs SET timed_lyrics = $1 WHERE id = $2`
+	res, err := storage.Conn(ctx, r.db).ExecContext(ctx, stmt, lyrics, id)
+	if err != nil {
+		r.log.Error("can't store timed lyrics", slog.Int("song_id", id), slog.Any("error", err))
+		return fmt.Errorf("can't store timed lyrics, err=%w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.log.Error("failed to fetch rows affected", slog.Any("error", err))
+		return fmt.Errorf("failed to fetch rows affected, err=%w", err)
+	}
+	if rowsAffected == 0 {
+		r.log.Debug("song not found", slog.Int("song_id", id))
+		return fmt.Errorf("song with ID %d %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *SongRepository) Get(ctx context.Context, filter models.SongFilter) (models.SongPage, error) {
 	r.log.Debug("start retrieving songs/songs from the database")
 
-	stmt := `SELECT s.id, s.song, g.name, s.text, s.link, s.releasedate 
-             FROM songs s 
-             JOIN groups g on s.group_name = g.name
-             WHERE 
-               ($1::text IS NULL OR g.name ILIKE $1) 
-               AND ($2::text IS NULL OR s.song ILIKE $2) 
-               AND ($3::int IS NULL OR s.id = $3)
-               AND ($4::text IS NULL OR s.releasedate = $4)
-             LIMIT $5 OFFSET $6`
+	where, score, args := songConditions(filter)
+
+	// the seek condition narrows the rows to the page but not the total
+	seek, seekArgs := where, args
+	if filter.After != nil {
+		cond, values := seekCondition(filter.After, len(args))
+		seek = where + " AND " + cond
+		seekArgs = append(append([]any{}, args...), values...)
+	}
 
-	r.log.Info("Parameters received", "groupName", groupName, "songName", songName, "limit", limit, "offset", offset, "songID", songID, "date", releaseDate)
+	order := songOrder(filter.Sort, score != noScore)
 
-	rows, err := r.db.Query(stmt, groupName, songName, songID, releaseDate, limit, offset)
+	seekArgs = append(seekArgs, filter.Limit, filter.Offset)
+	stmt := fmt.Sprintf(`SELECT s.id, s.group_id, g.name, s.song, s.text, s.text_format, s.link, s.releasedate, %s AS score,
+                    count(*) OVER () AS matched
+             FROM songs s
+             JOIN groups g on s.group_id = g.id
+             WHERE %s
+             ORDER BY %s
+             LIMIT $%d OFFSET $%d`, score, seek, order, len(seekArgs)-1, len(seekArgs))
+
+	r.log.Info("Parameters received", slog.Any("filter", filter))
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, stmt, seekArgs...)
 	if err != nil {
-		r.log.Error("can't fetch all songs, err", err)
-		return nil, fmt.Errorf("can't fetch all songs, err=%v", err)
+		r.log.Error("can't fetch all songs", slog.Any("error", err))
+		return models.SongPage{}, fmt.Errorf("can't fetch all songs, err=%w", err)
 	}
 	defer rows.Close()
 
-	var songs []models.Song
+	page := models.SongPage{Songs: []models.Song{}}
+	var matched int
 	for rows.Next() {
 		var song models.Song
-		err = rows.Scan(&song.ID, &song.Song, &song.GroupName, &song.Text, &song.Link, &song.ReleaseDate)
+		err = rows.Scan(&song.ID, &song.GroupID, &song.GroupName, &song.Song, &song.Text, &song.TextFormat, &song.Link, &song.ReleaseDate, &song.Score, &matched)
 		if err != nil {
-			r.log.Error("error scannin row, err", err)
-			return nil, fmt.Errorf("error scannin row, err=%v", err)
+			r.log.Error("error scannin row", slog.Any("error", err))
+			return models.SongPage{}, fmt.Errorf("error scannin row, err=%w", err)
 		}
-		songs = append(songs, song)
+		page.Songs = append(page.Songs, song)
 	}
 
 	err = rows.Err()
 	if err != nil {
-		r.log.Error("rows error, err", err)
-		return nil, fmt.Errorf("rows error, err=%v", err)
+		r.log.Error("rows error", slog.Any("error", err))
+		return models.SongPage{}, fmt.Errorf("rows error, err=%w", err)
+	}
+
+	page.HasNext = filter.Offset+len(page.Songs) < matched
+
+	// the window count is the total unless a cursor narrowed the rows or the
+	// page is past the end and returned no count at all
+	page.Total = matched
+	if filter.After != nil || (len(page.Songs) == 0 && filter.Offset > 0) {
+		if page.Total, err = r.count(ctx, where, args); err != nil {
+			return models.SongPage{}, err
+		}
+	}
+
+	r.log.Debug("songs fetched", slog.Int("count", len(page.Songs)), slog.Int("total", page.Total))
+
+	return page, nil
+}
+
+// count returns the number of songs matching where
+func (r *SongRepository) count(ctx context.Context, where string, args []any) (int, error) {
+	stmt := fmt.Sprintf(`SELECT count(*)
+             FROM songs s
+             JOIN groups g on s.group_id = g.id
+             WHERE %s`, where)
+
+	var total int
+	if err := storage.Conn(ctx, r.db).QueryRowContext(ctx, stmt, args...).Scan(&total); err != nil {
+		r.log.Error("can't count songs", slog.Any("error", err))
+		return 0, fmt.Errorf("can't count songs, err=%w", err)
+	}
+
+	return total, nil
+}
+
+// sortColumns maps the sortable fields to their columns
+var sortColumns = map[string]string{
+	models.SortByID:          "s.id",
+	models.SortBySong:        "s.song",
+	models.SortByGroupName:   "g.name",
+	models.SortByReleaseDate: "s.releasedate",
+}
+
+// songOrder builds the ORDER BY list for sort. Without an explicit order
+// fuzzy matches come by score. The id is always the last key so pages never
+// overlap.
+func songOrder(sort []models.SortField, scored bool) string {
+	var keys []string
+	if len(sort) == 0 && scored {
+		keys = append(keys, "score DESC")
 	}
 
-	r.log.Debug("Parameters received", "groupName", groupName, "songName", songName, "limit", limit, "offset", offset, "songID", songID, "date", releaseDate)
+	for _, f := range seekKeys(sort) {
+		dir := "ASC"
+		if f.Desc {
+			dir = "DESC"
+		}
+		keys = append(keys, sortExpr(f, sortColumns[f.Field])+" "+dir)
+	}
+
+	return strings.Join(keys, ", ")
+}
+
+// seekKeys returns the known fields of sort followed by the id tie-breaker
+func seekKeys(sort []models.SortField) []models.SortField {
+	var (
+		keys []models.SortField
+		byID bool
+	)
+	for _, f := range sort {
+		if _, ok := sortColumns[f.Field]; !ok {
+			continue
+		}
+		byID = byID || f.Field == models.SortByID
+		keys = append(keys, f)
+	}
+
+	if !byID {
+		keys = append(keys, models.SortField{Field: models.SortByID})
+	}
+
+	return keys
+}
+
+// sortExpr wraps value, a column or a parameter of field, into the expression
+// songs are ordered by. Missing release dates sort last in both directions,
+// which keeps keyset comparisons free of NULLs.
+func sortExpr(f models.SortField, value string) string {
+	if f.Field != models.SortByReleaseDate {
+		return value
+	}
+	if f.Desc {
+		return fmt.Sprintf("COALESCE(%s::date, '-infinity'::date)", value)
+	}
+
+	return fmt.Sprintf("COALESCE(%s::date, 'infinity'::date)", value)
+}
+
+// cursorValue returns the value of field stored in cursor
+func cursorValue(cursor *models.SongCursor, field string) any {
+	switch field {
+	case models.SortBySong:
+		return cursor.Song
+	case models.SortByGroupName:
+		return cursor.GroupName
+	case models.SortByReleaseDate:
+		return cursor.ReleaseDate
+	}
+
+	return cursor.ID
+}
+
+// seekCondition matches the songs ordered after cursor: the first key that
+// differs from the cursor decides. Its placeholders continue after argc.
+func seekCondition(cursor *models.SongCursor, argc int) (string, []any) {
+	var (
+		args    []any
+		values  []string
+		ors     []string
+		columns []string
+	)
+	for _, f := range seekKeys(cursor.Sort) {
+		args = append(args, cursorValue(cursor, f.Field))
+		values = append(values, sortExpr(f, fmt.Sprintf("$%d", argc+len(args))))
+		columns = append(columns, sortExpr(f, sortColumns[f.Field]))
+
+		op := ">"
+		if f.Desc {
+			op = "<"
+		}
+
+		ands := make([]string, 0, len(values))
+		for i := range len(values) - 1 {
+			ands = append(ands, columns[i]+" = "+values[i])
+		}
+		ands = append(ands, columns[len(columns)-1]+" "+op+" "+values[len(values)-1])
+
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// noScore is selected as the score of songs not matched fuzzily
+const noScore = "NULL::float8"
+
+// songConditions turns the set fields of filter into a WHERE clause over
+// songs s joined with groups g, with its positional arguments. For fuzzy
+// matches score is the mean similarity of the matched names, noScore otherwise.
+func songConditions(filter models.SongFilter) (where, score string, args []any) {
+	var (
+		conds  []string
+		scores []string
+	)
+	add := func(cond string, value any) {
+		args = append(args, value)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	name := func(column, value string) {
+		switch filter.Match {
+		case models.MatchPrefix:
+			add(column+" ILIKE $%d", escapeLike(value)+"%")
+		case models.MatchContains:
+			add(column+" ILIKE $%d", "%"+escapeLike(value)+"%")
+		case models.MatchFuzzy:
+			// word similarity lets a short query match one word of a longer name
+			add("$%d <%% "+column, value)
+			scores = append(scores, fmt.Sprintf("word_similarity($%d, %s)", len(args), column))
+		default:
+			add(column+" ILIKE $%d", escapeLike(value))
+		}
+	}
+
+	if filter.ID != 0 {
+		add("s.id = $%d", filter.ID)
+	}
+	if filter.GroupID != 0 {
+		add("s.group_id = $%d", filter.GroupID)
+	}
+	if filter.GroupName != "" {
+		name("g.name", filter.GroupName)
+	}
+	if filter.Song != "" {
+		name("s.song", filter.Song)
+	}
+	if !filter.ReleaseDate.IsZero() {
+		add("s.releasedate = $%d", filter.ReleaseDate)
+	}
+	if !filter.ReleasedFrom.IsZero() {
+		add("s.releasedate >= $%d", filter.ReleasedFrom)
+	}
+	if !filter.ReleasedTo.IsZero() {
+		add("s.releasedate <= $%d", filter.ReleasedTo)
+	}
+	if filter.Year != 0 {
+		// a range instead of EXTRACT keeps idx_release_date usable
+		add("s.releasedate >= $%d", models.NewDate(filter.Year, time.January, 1))
+		add("s.releasedate < $%d", models.NewDate(filter.Year+1, time.January, 1))
+	}
+
+	where, score = "TRUE", noScore
+	if len(conds) > 0 {
+		where = strings.Join(conds, " AND ")
+	}
+	if len(scores) > 0 {
+		score = fmt.Sprintf("(%s) / %d", strings.Join(scores, " + "), len(scores))
+	}
+
+	return where, score, args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
 
-	return songs, nil
+// escapeLike makes LIKE wildcards in user input match literally
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
 }
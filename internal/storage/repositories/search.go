@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+	"github.com/Fyefhqdishka/eff-mobile/internal/storage"
+	"log/slog"
+	"unicode"
+)
+
+// text search configurations by models.SearchLang* value
+var searchConfigs = map[string]string{
+	models.SearchLangEnglish: "english",
+	models.SearchLangRussian: "russian",
+}
+
+// Search runs a ranked full-text search over song titles, group names and
+// lyrics using the search_vector column
+func (r *SongRepository) Search(ctx context.Context, query models.SearchQuery) ([]models.SongSearchResult, error) {
+	r.log.Debug("start searching songs", slog.String("query", query.Query), slog.String("lang", query.Language))
+
+	tsquery := `websearch_to_tsquery('english', $1) || websearch_to_tsquery('russian', $1)`
+	if config, ok := searchConfigs[query.Language]; ok {
+		tsquery = fmt.Sprintf(`websearch_to_tsquery('%s', $1)`, config)
+	}
+
+	stmt := fmt.Sprintf(`WITH q AS (SELECT %s AS query)
+             SELECT s.id, s.group_id, g.name, s.song, s.text, s.text_format, s.link, s.releasedate,
+                    ts_rank(s.search_vector, q.query) AS rank,
+                    ts_headline($2::regconfig, song_lyrics(s.text, s.text_format), q.query, 'MaxFragments=2, MinWords=5, MaxWords=20') AS snippet
+             FROM songs s
+             JOIN groups g ON g.id = s.group_id
+             CROSS JOIN q
+             WHERE s.search_vector @@ q.query
+             ORDER BY rank DESC, s.id
+             LIMIT $3 OFFSET $4`, tsquery)
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, stmt, query.Query, headlineConfig(query), query.Limit, query.Offset)
+	if err != nil {
+		r.log.Error("can't search songs", slog.Any("error", err))
+		return nil, fmt.Errorf("can't search songs, err=%w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SongSearchResult{}
+	for rows.Next() {
+		var res models.SongSearchResult
+		err = rows.Scan(&res.ID, &res.GroupID, &res.GroupName, &res.Song.Song, &res.Text, &res.TextFormat, &res.Link, &res.ReleaseDate, &res.Rank, &res.Snippet)
+		if err != nil {
+			r.log.Error("error scannin row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scannin row, err=%w", err)
+		}
+		results = append(results, res)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.log.Error("rows error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows error, err=%w", err)
+	}
+
+	r.log.Debug("songs found", slog.Int("count", len(results)))
+
+	return results, nil
+}
+
+// headlineConfig picks the configuration used to highlight matches. Without
+// an explicit language the script of the query decides.
+func headlineConfig(query models.SearchQuery) string {
+	if config, ok := searchConfigs[query.Language]; ok {
+		return config
+	}
+
+	for _, r := range query.Query {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return searchConfigs[models.SearchLangRussian]
+		}
+	}
+
+	return searchConfigs[models.SearchLangEnglish]
+}
@@ -1,10 +1,40 @@
 package storageInterfaces
 
-import "github.com/Fyefhqdishka/eff-mobile/internal/models"
+import (
+	"context"
+	"time"
+
+	"github.com/Fyefhqdishka/eff-mobile/internal/models"
+)
 
 type Storage interface {
-	Create(song models.Song) (int, error)
-	Update(song models.Song) (bool, error)
-	Delete(song models.Song) (int, error)
-	Get(groupName, songName, releaseDate string, limit, offset, songID int) ([]models.Song, error)
+	Create(ctx context.Context, song models.Song) (int, error)
+	Update(ctx context.Context, song models.Song) (bool, error)
+	Patch(ctx context.Context, id int, patch models.SongPatch) (models.Song, error)
+	Delete(ctx context.Context, id int) (int, error)
+	GetByID(ctx context.Context, id int) (models.Song, error)
+	Get(ctx context.Context, filter models.SongFilter) (models.SongPage, error)
+	Search(ctx context.Context, query models.SearchQuery) ([]models.SongSearchResult, error)
+	SetTimedLyrics(ctx context.Context, id int, lyrics models.TimedLyrics) error
+	ClaimEnrichment(ctx context.Context, limit int, lease time.Duration) ([]models.EnrichmentJob, error)
+	SetEnrichment(ctx context.Context, id int, state models.Enrichment) error
+	ReleaseEnrichment(ctx context.Context, id int, claimedUntil time.Time, state models.Enrichment) error
+	GetEnrichment(ctx context.Context, id int) (models.Enrichment, error)
+}
+
+type GroupStorage interface {
+	Create(ctx context.Context, name string) (models.Group, error)
+	Ensure(ctx context.Context, name string) (models.Group, error)
+	Rename(ctx context.Context, id int, name string) (models.Group, error)
+	Merge(ctx context.Context, fromID, intoID int) (models.Group, error)
+	GetByName(ctx context.Context, name string) (models.Group, error)
+	Delete(ctx context.Context, id int) (int, error)
+	GetByID(ctx context.Context, id int) (models.Group, error)
+	Get(ctx context.Context, limit, offset int) ([]models.Group, error)
+}
+
+// Transactor runs fn atomically, every repository call made with the context
+// passed to fn joins the same transaction
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
 }
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log/slog"
+	"time"
+)
+
+// Postgres error codes of failures that are safe to retry with a new transaction
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// default settings of WithinTx. Serializable transactions are what makes
+// Postgres report the serialization failures retried by WithinTx.
+const (
+	DefaultTxIsolation    = sql.LevelSerializable
+	DefaultTxRetries      = 3
+	DefaultTxRetryBackoff = 20 * time.Millisecond
+)
+
+// DBTX is the part of *sql.DB and *sql.Tx the repositories use, so they can
+// run the same queries inside and outside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// Conn returns the transaction carried by ctx, or db when there is none.
+// Repositories call it for every query so they take part in the unit of work
+// started by TxManager.WithinTx.
+func Conn(ctx context.Context, db *sql.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return db
+}
+
+// TxManager runs units of work spanning several repositories in one transaction
+type TxManager struct {
+	db        *sql.DB
+	log       *slog.Logger
+	isolation sql.IsolationLevel
+	retries   int
+	backoff   time.Duration
+}
+
+func NewTxManager(db *sql.DB, log *slog.Logger) *TxManager {
+	return &TxManager{
+		db:        db,
+		log:       log,
+		isolation: DefaultTxIsolation,
+		retries:   DefaultTxRetries,
+		backoff:   DefaultTxRetryBackoff,
+	}
+}
+
+// WithIsolation returns a copy of the manager starting transactions at level.
+// Serialization failures are only retried at the levels that raise them.
+func (m *TxManager) WithIsolation(level sql.IsolationLevel) *TxManager {
+	c := *m
+	c.isolation = level
+	return &c
+}
+
+// WithinTx runs fn in a transaction passed down through the context. The
+// transaction is committed when fn returns nil and rolled back when it fails
+// or panics.
+// Serialization failures and deadlocks restart fn in a fresh transaction, so
+// fn must not have side effects outside the database. Nested calls join the
+// outer transaction.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = m.run(ctx, fn)
+		if err == nil || !isRetryable(err) || attempt >= m.retries {
+			return err
+		}
+
+		m.log.Warn("transaction conflict, retrying", slog.Int("attempt", attempt+1), slog.Any("error", err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.backoff * time.Duration(attempt+1)):
+		}
+	}
+}
+
+func (m *TxManager) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := m.db.BeginTx(ctx, &sql.TxOptions{Isolation: m.isolation})
+	if err != nil {
+		return fmt.Errorf("can't begin transaction, err=%w", err)
+	}
+
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			m.log.Error("failed to rollback transaction", slog.Any("error", rbErr))
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	committed = true
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit transaction, err=%w", err)
+	}
+
+	return nil
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
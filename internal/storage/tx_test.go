@@ -0,0 +1,140 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Fyefhqdishka/eff-mobile/internal/storage"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTxManager(t *testing.T) (*storage.TxManager, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return storage.NewTxManager(db, slog.New(slog.NewTextHandler(io.Discard, nil))), mock
+}
+
+func TestWithinTxCommits(t *testing.T) {
+	tx, mock := newTxManager(t)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	err := tx.WithinTx(context.Background(), func(ctx context.Context) error { return nil })
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWithinTxRollsBackOnError(t *testing.T) {
+	tx, mock := newTxManager(t)
+	failed := errors.New("failed")
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err := tx.WithinTx(context.Background(), func(ctx context.Context) error { return failed })
+	assert.ErrorIs(t, err, failed)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWithinTxRollsBackOnPanic(t *testing.T) {
+	tx, mock := newTxManager(t)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = tx.WithinTx(context.Background(), func(ctx context.Context) error { panic("boom") })
+	})
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWithinTxRetriesConflicts(t *testing.T) {
+	for _, code := range []pq.ErrorCode{"40001", "40P01"} {
+		t.Run(string(code), func(t *testing.T) {
+			tx, mock := newTxManager(t)
+			conflict := &pq.Error{Code: code}
+
+			// the first attempt and all the retries conflict
+			for range storage.DefaultTxRetries + 1 {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			}
+
+			calls := 0
+			err := tx.WithinTx(context.Background(), func(ctx context.Context) error {
+				calls++
+				return conflict
+			})
+			assert.ErrorIs(t, err, conflict)
+			assert.Equal(t, storage.DefaultTxRetries+1, calls)
+			assert.Nil(t, mock.ExpectationsWereMet())
+
+			// a retry that doesn't conflict commits
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+			mock.ExpectBegin()
+			mock.ExpectCommit()
+
+			calls = 0
+			err = tx.WithinTx(context.Background(), func(ctx context.Context) error {
+				calls++
+				if calls == 1 {
+					return conflict
+				}
+				return nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, 2, calls)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWithinTxDoesNotRetryOtherErrors(t *testing.T) {
+	tx, mock := newTxManager(t)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	calls := 0
+	err := tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		calls++
+		return &pq.Error{Code: "23505"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWithinTxNestedJoinsOuter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx := storage.NewTxManager(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// a single transaction runs the statement of the inner unit of work
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM songs").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		return tx.WithinTx(ctx, func(ctx context.Context) error {
+			_, err := storage.Conn(ctx, db).ExecContext(ctx, "DELETE FROM songs WHERE id = $1", 1)
+			return err
+		})
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
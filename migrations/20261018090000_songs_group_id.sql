@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE songs ADD COLUMN group_id integer;
+
+UPDATE songs s SET group_id = g.id FROM groups g WHERE g.name = s.group_name;
+
+ALTER TABLE songs ALTER COLUMN group_id SET NOT NULL;
+ALTER TABLE songs ADD CONSTRAINT songs_group_id_fkey
+    FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_group_name;
+ALTER TABLE songs DROP COLUMN group_name;
+
+CREATE INDEX idx_group_id ON songs(group_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE songs ADD COLUMN group_name varchar(255);
+
+UPDATE songs s SET group_name = g.name FROM groups g WHERE g.id = s.group_id;
+
+ALTER TABLE songs ALTER COLUMN group_name SET NOT NULL;
+ALTER TABLE songs ADD CONSTRAINT songs_group_name_fkey
+    FOREIGN KEY (group_name) REFERENCES groups(name) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_group_id;
+ALTER TABLE songs DROP COLUMN group_id;
+
+CREATE INDEX idx_group_name ON songs(group_name);
+
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- release dates were stored as DD.MM.YYYY strings, values in neither that
+-- nor ISO format can't be recovered and become NULL. So do values in the
+-- right format that aren't real dates, like 31.02.2020, on which to_date
+-- raises instead.
+CREATE OR REPLACE FUNCTION legacy_release_date(val text) RETURNS date
+LANGUAGE plpgsql IMMUTABLE AS $$
+BEGIN
+    IF val ~ '^\d{2}\.\d{2}\.\d{4}$' THEN
+        RETURN to_date(val, 'DD.MM.YYYY');
+    ELSIF val ~ '^\d{4}-\d{2}-\d{2}' THEN
+        RETURN to_date(substr(val, 1, 10), 'YYYY-MM-DD');
+    END IF;
+
+    RETURN NULL;
+EXCEPTION WHEN data_exception THEN
+    RETURN NULL;
+END
+$$;
+
+ALTER TABLE songs ALTER COLUMN releasedate DROP NOT NULL;
+
+ALTER TABLE songs ALTER COLUMN releasedate TYPE date USING legacy_release_date(releasedate);
+
+DROP FUNCTION legacy_release_date(text);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE songs ALTER COLUMN releasedate TYPE varchar(255)
+    USING coalesce(to_char(releasedate, 'DD.MM.YYYY'), '');
+
+ALTER TABLE songs ALTER COLUMN releasedate SET NOT NULL;
+
+-- +goose StatementEnd
@@ -0,0 +1,69 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- The search vector covers the song title, its group name and the lyrics in
+-- both the english and the russian configuration. The group name lives in
+-- groups, which a generated column can't reference, so the column is kept up
+-- to date by triggers on songs and groups instead.
+CREATE OR REPLACE FUNCTION song_search_vector(song text, group_name text, lyrics text)
+RETURNS tsvector
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT setweight(to_tsvector('english', coalesce(song, '')), 'A')
+        || setweight(to_tsvector('russian', coalesce(song, '')), 'A')
+        || setweight(to_tsvector('english', coalesce(group_name, '')), 'B')
+        || setweight(to_tsvector('russian', coalesce(group_name, '')), 'B')
+        || setweight(to_tsvector('english', coalesce(lyrics, '')), 'C')
+        || setweight(to_tsvector('russian', coalesce(lyrics, '')), 'C')
+$$;
+
+ALTER TABLE songs ADD COLUMN search_vector tsvector;
+
+UPDATE songs s SET search_vector = song_search_vector(s.song, g.name, s.text)
+FROM groups g WHERE g.id = s.group_id;
+
+CREATE OR REPLACE FUNCTION songs_search_vector_refresh() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.search_vector := song_search_vector(
+        NEW.song,
+        (SELECT name FROM groups WHERE id = NEW.group_id),
+        NEW.text
+    );
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER songs_search_vector_refresh
+    BEFORE INSERT OR UPDATE OF song, text, group_id ON songs
+    FOR EACH ROW EXECUTE FUNCTION songs_search_vector_refresh();
+
+CREATE OR REPLACE FUNCTION groups_search_vector_refresh() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE songs SET search_vector = song_search_vector(song, NEW.name, text)
+    WHERE group_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER groups_search_vector_refresh
+    AFTER UPDATE OF name ON groups
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION groups_search_vector_refresh();
+
+CREATE INDEX idx_songs_search_vector ON songs USING GIN (search_vector);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TRIGGER IF EXISTS groups_search_vector_refresh ON groups;
+DROP FUNCTION IF EXISTS groups_search_vector_refresh();
+DROP TRIGGER IF EXISTS songs_search_vector_refresh ON songs;
+DROP FUNCTION IF EXISTS songs_search_vector_refresh();
+DROP INDEX IF EXISTS idx_songs_search_vector;
+ALTER TABLE songs DROP COLUMN IF EXISTS search_vector;
+DROP FUNCTION IF EXISTS song_search_vector(text, text, text);
+
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Trigram indexes back the prefix, contains and fuzzy name matching of
+-- GET /songs, ILIKE with wildcards and the <% word similarity operator.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_songs_song_trgm ON songs USING GIN (song gin_trgm_ops);
+CREATE INDEX idx_groups_name_trgm ON groups USING GIN (name gin_trgm_ops);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_groups_name_trgm;
+DROP INDEX IF EXISTS idx_songs_song_trgm;
+
+DROP EXTENSION IF EXISTS pg_trgm;
+
+-- +goose StatementEnd
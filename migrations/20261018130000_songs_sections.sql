@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Lyrics parsed into typed sections ([Verse 2], [Chorus], ...). The column is
+-- written by the application whenever the text changes, rows stored before
+-- it existed stay NULL and are parsed on read.
+ALTER TABLE songs ADD COLUMN sections jsonb;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE songs DROP COLUMN IF EXISTS sections;
+
+-- +goose StatementEnd
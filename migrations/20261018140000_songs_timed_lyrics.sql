@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Lyrics synced to the playback, imported from LRC files
+ALTER TABLE songs ADD COLUMN timed_lyrics jsonb;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE songs DROP COLUMN IF EXISTS timed_lyrics;
+
+-- +goose StatementEnd
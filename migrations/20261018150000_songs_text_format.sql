@@ -0,0 +1,81 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- How the song text is written: plain lyrics with optional section markup or
+-- ChordPro with chords inline.
+ALTER TABLE songs ADD COLUMN text_format text NOT NULL DEFAULT 'plain'
+    CHECK (text_format IN ('plain', 'chordpro'));
+
+-- song_lyrics drops the [chords] and {directive} lines of ChordPro text so
+-- they don't end up in the search vector.
+CREATE OR REPLACE FUNCTION song_lyrics(lyrics text, format text)
+RETURNS text
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE WHEN format = 'chordpro'
+        THEN regexp_replace(regexp_replace(lyrics, '^\s*\{[^}]*\}\s*$', '', 'gn'), '\[[^]]*\]', '', 'g')
+        ELSE lyrics
+    END
+$$;
+
+CREATE OR REPLACE FUNCTION songs_search_vector_refresh() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.search_vector := song_search_vector(
+        NEW.song,
+        (SELECT name FROM groups WHERE id = NEW.group_id),
+        song_lyrics(NEW.text, NEW.text_format)
+    );
+    RETURN NEW;
+END
+$$;
+
+DROP TRIGGER IF EXISTS songs_search_vector_refresh ON songs;
+CREATE TRIGGER songs_search_vector_refresh
+    BEFORE INSERT OR UPDATE OF song, text, text_format, group_id ON songs
+    FOR EACH ROW EXECUTE FUNCTION songs_search_vector_refresh();
+
+CREATE OR REPLACE FUNCTION groups_search_vector_refresh() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE songs SET search_vector = song_search_vector(song, NEW.name, song_lyrics(text, text_format))
+    WHERE group_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+CREATE OR REPLACE FUNCTION groups_search_vector_refresh() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE songs SET search_vector = song_search_vector(song, NEW.name, text)
+    WHERE group_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS songs_search_vector_refresh ON songs;
+
+CREATE OR REPLACE FUNCTION songs_search_vector_refresh() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.search_vector := song_search_vector(
+        NEW.song,
+        (SELECT name FROM groups WHERE id = NEW.group_id),
+        NEW.text
+    );
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER songs_search_vector_refresh
+    BEFORE INSERT OR UPDATE OF song, text, group_id ON songs
+    FOR EACH ROW EXECUTE FUNCTION songs_search_vector_refresh();
+
+DROP FUNCTION IF EXISTS song_lyrics(text, text);
+ALTER TABLE songs DROP COLUMN IF EXISTS text_format;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Songs are enriched from the music info API in the background. Pending songs
+-- are claimed by moving enrichment_next_at past a lease, so a song whose
+-- worker died is picked up again once the lease runs out. Songs stored before
+-- the columns existed were enriched synchronously and count as done.
+ALTER TABLE songs
+    ADD COLUMN enrichment_status text NOT NULL DEFAULT 'done'
+        CHECK (enrichment_status IN ('pending', 'done', 'failed', 'skipped')),
+    ADD COLUMN enrichment_attempts integer NOT NULL DEFAULT 0,
+    ADD COLUMN enrichment_error text,
+    ADD COLUMN enrichment_next_at timestamptz NOT NULL DEFAULT now();
+
+CREATE INDEX idx_songs_enrichment_pending ON songs (enrichment_next_at, id)
+    WHERE enrichment_status = 'pending';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_songs_enrichment_pending;
+ALTER TABLE songs
+    DROP COLUMN IF EXISTS enrichment_next_at,
+    DROP COLUMN IF EXISTS enrichment_error,
+    DROP COLUMN IF EXISTS enrichment_attempts,
+    DROP COLUMN IF EXISTS enrichment_status;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Names of the providers the enriched fields came from, e.g.
+-- {"text": "primary", "link": "backup"}. Songs enriched by a single provider
+-- have none.
+ALTER TABLE songs ADD COLUMN enrichment_sources jsonb;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE songs DROP COLUMN IF EXISTS enrichment_sources;
+
+-- +goose StatementEnd
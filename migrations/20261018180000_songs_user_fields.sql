@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Enrichable fields (text, link, releasedate) the user set. Every field
+-- existing songs have counts as set by the user, so that enrichment never
+-- overwrites them.
+ALTER TABLE songs ADD COLUMN user_fields jsonb NOT NULL DEFAULT '[]'::jsonb;
+
+UPDATE songs SET user_fields = to_jsonb(array_remove(ARRAY[
+    CASE WHEN text <> '' THEN 'text' END,
+    CASE WHEN link <> '' THEN 'link' END,
+    CASE WHEN releasedate IS NOT NULL THEN 'releasedate' END
+], NULL));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE songs DROP COLUMN IF EXISTS user_fields;
+
+-- +goose StatementEnd
@@ -3,6 +3,7 @@ package routes
 import (
 	"encoding/json"
 	_ "github.com/Fyefhqdishka/eff-mobile/docs"
+	"github.com/Fyefhqdishka/eff-mobile/internal/client"
 	"github.com/Fyefhqdishka/eff-mobile/internal/handlers"
 	"github.com/Fyefhqdishka/eff-mobile/internal/models"
 	"github.com/gorilla/mux"
@@ -13,26 +14,49 @@ import (
 
 func RegisterRoutes(r *mux.Router, h handlers.Handlers) {
 	songRoutes(r, h)
+	groupRoutes(r, h)
+	r.HandleFunc("/health/upstream", h.GetUpstreamStatus).Methods("GET")
+}
+
+func groupRoutes(r *mux.Router, h handlers.Handlers) {
+	r.HandleFunc("/groups", h.CreateGroup).Methods("POST")
+	r.HandleFunc("/groups", h.GetGroups).Methods("GET")
+	r.HandleFunc("/groups/{id}", h.GetGroup).Methods("GET")
+	r.HandleFunc("/groups/{id}", h.RenameGroup).Methods("PUT")
+	r.HandleFunc("/groups/{id}", h.DeleteGroup).Methods("DELETE")
+	r.HandleFunc("/groups/{id}/songs", h.GetGroupSongs).Methods("GET")
+	r.HandleFunc("/groups/{id}/refresh", h.RefreshGroupSongs).Methods("POST")
 }
 
 func songRoutes(r *mux.Router, h handlers.Handlers) {
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler).Methods("GET")
 	r.HandleFunc("/songs", h.Create).Methods("POST")
 	r.HandleFunc("/songs", h.Get).Methods("GET")
+	r.HandleFunc("/songs/verses", h.GetVerses).Methods("GET")
+	r.HandleFunc("/songs/search", h.Search).Methods("GET")
+	r.HandleFunc("/songs/{id}", h.GetByID).Methods("GET")
 	r.HandleFunc("/songs/{id}", h.Update).Methods("PUT")
+	r.HandleFunc("/songs/{id}", h.Patch).Methods("PATCH")
 	r.HandleFunc("/songs/{id}", h.Delete).Methods("DELETE")
-	r.HandleFunc("/songs/verses", h.GetVerses).Methods("GET")
+	r.HandleFunc("/songs/{id}/group", h.SetSongGroup).Methods("PUT")
+	r.HandleFunc("/songs/{id}/verses", h.GetSongVerses).Methods("GET")
+	r.HandleFunc("/songs/{id}/sections", h.GetSongSections).Methods("GET")
+	r.HandleFunc("/songs/{id}/chords", h.GetSongChords).Methods("GET")
+	r.HandleFunc("/songs/{id}/enrichment", h.GetSongEnrichment).Methods("GET")
+	r.HandleFunc("/songs/{id}/refresh", h.RefreshSong).Methods("POST")
+	r.HandleFunc("/songs/{id}/lyrics", h.UploadLyrics).Methods("POST")
+	r.HandleFunc("/songs/{id}/lyrics", h.GetLyrics).Methods("GET")
+	r.HandleFunc("/songs/{id}/lyrics.lrc", h.ExportLyrics).Methods("GET")
+	r.HandleFunc("/songs/{id}/lyrics/at", h.GetLyricsAt).Methods("GET")
 
 	r.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
-		var song models.Song
-
-		song.Song = r.URL.Query().Get("song")
-		song.GroupName = r.URL.Query().Get("group")
-		song.Text = "first verse\n\nsecond verse\n\nthird verse\n\nfourth verse\n\n"
-		song.Link = "https://www.youtube.com/watch?v=HRbW75fYLvo&t=326629s"
-		song.ReleaseDate = time.Now().Format("02.01.2006")
+		detail := client.SongDetail{
+			ReleaseDate: time.Now().Format(models.LegacyDateLayout),
+			Text:        "first verse\n\nsecond verse\n\nthird verse\n\nfourth verse\n\n",
+			Link:        "https://www.youtube.com/watch?v=HRbW75fYLvo&t=326629s",
+		}
 
-		data, _ := json.Marshal(song)
+		data, _ := json.Marshal(detail)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)